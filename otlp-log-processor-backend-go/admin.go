@@ -0,0 +1,234 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const adminStatsTimeout = 5 * time.Second
+
+// valueCount is the count of a single log value.
+type valueCount struct {
+	Value string `json:"value"`
+	Count uint64 `json:"count"`
+}
+
+// statsResponse is the JSON representation of the counts of a window.
+type statsResponse struct {
+	Window         string       `json:"window"`
+	Start          *time.Time   `json:"start,omitempty"`
+	End            *time.Time   `json:"end,omitempty"`
+	DistinctValues int          `json:"distinctValues"`
+	Values         []valueCount `json:"values"`
+	Anomalies      []anomaly    `json:"anomalies,omitempty"`
+}
+
+// valueInfo is when a single log value was seen.
+type valueInfo struct {
+	Value     string    `json:"value"`
+	Count     uint64    `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+	Stale     bool      `json:"stale"`
+}
+
+// valuesResponse is the JSON representation of the seen values.
+type valuesResponse struct {
+	DistinctValues int         `json:"distinctValues"`
+	StaleValues    int         `json:"staleValues"`
+	Values         []valueInfo `json:"values"`
+}
+
+// statsSource provides a consistent copy of the processor state.
+type statsSource interface {
+	Stats(ctx context.Context) (processorStats, error)
+}
+
+// newAdminHandler serves the counts of the processor as JSON.
+//
+//	GET /stats/current  the window which is currently counted
+//	GET /stats/last     the last completed window and its anomalies
+//	GET /stats/total    the cumulative counts
+//	GET /values         when every value was seen first and last
+//
+// The query parameters prefix, sort (count or value), order (asc or desc) and limit narrow down the values.
+// For /values, stale=true returns only the values which stopped sending.
+func newAdminHandler(source statsSource) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats/{window}", func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseStatsQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), adminStatsTimeout)
+		defer cancel()
+		stats, err := source.Stats(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		response := statsResponse{Window: r.PathValue("window")}
+		var counts map[string]uint64
+		switch response.Window {
+		case "current":
+			response.Start, response.End = &stats.Current.Start, &stats.Current.End
+			counts = stats.Current.Counts
+		case "last":
+			if !stats.Last.Start.IsZero() {
+				response.Start, response.End = &stats.Last.Start, &stats.Last.End
+			}
+			counts = stats.Last.Counts
+			response.Anomalies = stats.Anomalies
+		case "total":
+			counts = stats.Total
+		default:
+			http.Error(w, fmt.Sprintf("unknown window %q, expected current, last or total", response.Window), http.StatusNotFound)
+			return
+		}
+		response.DistinctValues = len(counts)
+		response.Values = query.apply(counts)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.WarnContext(r.Context(), "Failed to write admin response", slog.Any("error", err))
+		}
+	})
+	mux.HandleFunc("GET /values", func(w http.ResponseWriter, r *http.Request) {
+		query, err := parseStatsQuery(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		onlyStale, err := parseStaleParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), adminStatsTimeout)
+		defer cancel()
+		stats, err := source.Stats(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		response := valuesResponse{DistinctValues: len(stats.Seen), Values: []valueInfo{}}
+		counts := make(map[string]uint64)
+		for value, seen := range stats.Seen {
+			stale := seen.stale(stats.StaleBefore)
+			if stale {
+				response.StaleValues++
+			}
+			if stale || !onlyStale {
+				counts[value] = stats.Total[value]
+			}
+		}
+		// The values are narrowed down and sorted like the counts of the windows.
+		for _, value := range query.apply(counts) {
+			seen := stats.Seen[value.Value]
+			response.Values = append(response.Values, valueInfo{
+				Value:     value.Value,
+				Count:     value.Count,
+				FirstSeen: seen.FirstSeen,
+				LastSeen:  seen.LastSeen,
+				Stale:     seen.stale(stats.StaleBefore),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			slog.WarnContext(r.Context(), "Failed to write admin response", slog.Any("error", err))
+		}
+	})
+	return mux
+}
+
+func parseStaleParam(r *http.Request) (bool, error) {
+	stale := r.URL.Query().Get("stale")
+	if stale == "" {
+		return false, nil
+	}
+	onlyStale, err := strconv.ParseBool(stale)
+	if err != nil {
+		return false, fmt.Errorf("invalid stale %q, expected true or false", stale)
+	}
+	return onlyStale, nil
+}
+
+type statsQuery struct {
+	prefix     string
+	sortBy     string
+	descending bool
+	limit      int
+}
+
+func parseStatsQuery(r *http.Request) (statsQuery, error) {
+	params := r.URL.Query()
+	query := statsQuery{
+		prefix:     params.Get("prefix"),
+		sortBy:     cmp.Or(params.Get("sort"), "count"),
+		descending: true,
+	}
+
+	if query.sortBy != "count" && query.sortBy != "value" {
+		return query, fmt.Errorf("invalid sort %q, expected count or value", query.sortBy)
+	}
+
+	switch params.Get("order") {
+	case "":
+		query.descending = query.sortBy == "count"
+	case "asc":
+		query.descending = false
+	case "desc":
+		query.descending = true
+	default:
+		return query, fmt.Errorf("invalid order %q, expected asc or desc", params.Get("order"))
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		var err error
+		query.limit, err = strconv.Atoi(limit)
+		if err != nil || query.limit < 0 {
+			return query, fmt.Errorf("invalid limit %q, expected a non-negative number", limit)
+		}
+	}
+
+	return query, nil
+}
+
+func (q statsQuery) apply(counts map[string]uint64) []valueCount {
+	values := make([]valueCount, 0, len(counts))
+	for value, count := range counts {
+		if strings.HasPrefix(value, q.prefix) {
+			values = append(values, valueCount{Value: value, Count: count})
+		}
+	}
+
+	slices.SortFunc(values, func(a, b valueCount) int {
+		c := strings.Compare(a.Value, b.Value)
+		if q.sortBy == "count" {
+			c = cmp.Compare(a.Count, b.Count)
+		}
+		if q.descending {
+			c = -c
+		}
+		return cmp.Or(c, strings.Compare(a.Value, b.Value))
+	})
+
+	if q.limit > 0 && len(values) > q.limit {
+		values = values[:q.limit]
+	}
+	return values
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fixedStatsSource processorStats
+
+func (s fixedStatsSource) Stats(context.Context) (processorStats, error) {
+	return processorStats(s), nil
+}
+
+func TestAdminHandler_Stats(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := fixedStatsSource{
+		Current: windowStats{
+			Start:  start.Add(10 * time.Second),
+			End:    start.Add(15 * time.Second),
+			Counts: map[string]uint64{"checkout": 3, "cart": 7, "payment": 1},
+		},
+		Last: windowStats{
+			Start:  start,
+			End:    start.Add(10 * time.Second),
+			Counts: map[string]uint64{"checkout": 12},
+		},
+		Total:     map[string]uint64{"checkout": 15, "cart": 7, "payment": 1, "checkin": 7},
+		Anomalies: []anomaly{{Value: "checkout", Count: 12, Expected: 2, StdDev: 1.5, Deviations: 6.7}},
+	}
+	handler := newAdminHandler(source)
+
+	tests := map[string]struct {
+		target            string
+		expectedStatus    int
+		expectedValues    []valueCount
+		expectedAnomalies []anomaly
+	}{
+		"Current_SortedByCountDescending": {
+			target:         "/stats/current",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueCount{{"cart", 7}, {"checkout", 3}, {"payment", 1}},
+		},
+		"Last": {
+			target:            "/stats/last",
+			expectedStatus:    http.StatusOK,
+			expectedValues:    []valueCount{{"checkout", 12}},
+			expectedAnomalies: source.Anomalies,
+		},
+		"Total_Prefix": {
+			target:         "/stats/total?prefix=check",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueCount{{"checkout", 15}, {"checkin", 7}},
+		},
+		"Total_TiesSortedByValue": {
+			target:         "/stats/total?limit=3",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueCount{{"checkout", 15}, {"cart", 7}, {"checkin", 7}},
+		},
+		"Total_SortByValue": {
+			target:         "/stats/total?sort=value&limit=2",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueCount{{"cart", 7}, {"checkin", 7}},
+		},
+		"Current_SortByCountAscending": {
+			target:         "/stats/current?sort=count&order=asc",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueCount{{"payment", 1}, {"checkout", 3}, {"cart", 7}},
+		},
+		"UnknownWindow": {
+			target:         "/stats/yesterday",
+			expectedStatus: http.StatusNotFound,
+		},
+		"InvalidLimit": {
+			target:         "/stats/current?limit=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"InvalidSort": {
+			target:         "/stats/current?sort=random",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			if recorder.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response statsResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if !reflect.DeepEqual(response.Values, test.expectedValues) {
+				t.Errorf("Expected values %v, got %v", test.expectedValues, response.Values)
+			}
+			if !reflect.DeepEqual(response.Anomalies, test.expectedAnomalies) {
+				t.Errorf("Expected anomalies %v, got %v", test.expectedAnomalies, response.Anomalies)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_Values(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := fixedStatsSource{
+		Total: map[string]uint64{"checkout": 15, "cart": 7, "payment": 1},
+		Seen: map[string]valueSeen{
+			"checkout": {FirstSeen: start, LastSeen: start.Add(2 * time.Hour)},
+			"cart":     {FirstSeen: start, LastSeen: start.Add(30 * time.Minute)},
+			"payment":  {FirstSeen: start.Add(time.Minute), LastSeen: start.Add(time.Minute)},
+		},
+		StaleBefore: start.Add(time.Hour),
+	}
+	handler := newAdminHandler(source)
+
+	tests := map[string]struct {
+		target         string
+		expectedStatus int
+		expectedValues []valueInfo
+	}{
+		"All": {
+			target:         "/values",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueInfo{
+				{Value: "checkout", Count: 15, FirstSeen: start, LastSeen: start.Add(2 * time.Hour)},
+				{Value: "cart", Count: 7, FirstSeen: start, LastSeen: start.Add(30 * time.Minute), Stale: true},
+				{Value: "payment", Count: 1, FirstSeen: start.Add(time.Minute), LastSeen: start.Add(time.Minute), Stale: true},
+			},
+		},
+		"Stale_SortByValue": {
+			target:         "/values?stale=true&sort=value&order=desc",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueInfo{
+				{Value: "payment", Count: 1, FirstSeen: start.Add(time.Minute), LastSeen: start.Add(time.Minute), Stale: true},
+				{Value: "cart", Count: 7, FirstSeen: start, LastSeen: start.Add(30 * time.Minute), Stale: true},
+			},
+		},
+		"Prefix": {
+			target:         "/values?prefix=check",
+			expectedStatus: http.StatusOK,
+			expectedValues: []valueInfo{{Value: "checkout", Count: 15, FirstSeen: start, LastSeen: start.Add(2 * time.Hour)}},
+		},
+		"InvalidStale": {
+			target:         "/values?stale=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, test.target, nil))
+
+			if recorder.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response valuesResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.DistinctValues != 3 || response.StaleValues != 2 {
+				t.Errorf("Expected 3 distinct and 2 stale values, got %d and %d", response.DistinctValues, response.StaleValues)
+			}
+			if !reflect.DeepEqual(response.Values, test.expectedValues) {
+				t.Errorf("Expected values %+v, got %+v", test.expectedValues, response.Values)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ProcessorWindows(t *testing.T) {
+	// Unbuffered, so every value is counted once the send returns.
+	logIntake := make(chan string)
+	processor := &dash0LogsProcessor{
+		logStats:       make(map[string]uint64),
+		logIntake:      logIntake,
+		durationWindow: time.Hour,
+		statsRequests:  make(chan chan processorStats),
+	}
+	go processor.StartLogProcessing()
+
+	logIntake <- "checkout"
+	logIntake <- "checkout"
+
+	recorder := httptest.NewRecorder()
+	newAdminHandler(processor).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/stats/current", nil))
+
+	var response statsResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Start == nil || response.End == nil {
+		t.Error("Expected the current window to have a start and end")
+	}
+	expected := []valueCount{{"checkout", 2}}
+	if !reflect.DeepEqual(response.Values, expected) {
+		t.Errorf("Expected values %v, got %v", expected, response.Values)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// The conditions of alert rules.
+const (
+	alertAbove  = "above"
+	alertBelow  = "below"
+	alertChange = "change"
+	alertAbsent = "absent"
+)
+
+// The statuses of alert events.
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+// alertRule fires when the count of a value in a closed window meets its condition. Exactly one condition must be set.
+// Without Value the rule applies to every value counted so far, each firing and resolving on its own.
+type alertRule struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value,omitempty"`
+	// Above fires if the count is above the threshold, Below if it is below.
+	Above *uint64 `yaml:"above,omitempty"`
+	Below *uint64 `yaml:"below,omitempty"`
+	// ChangePercent fires if the count changed by at least the percentage against the previous window,
+	// a negative percentage if it dropped by at least that much. Values absent from the previous window are skipped.
+	ChangePercent float64 `yaml:"changePercent,omitempty"`
+	// AbsentWindows fires if the value was not counted for the number of successive windows.
+	AbsentWindows int `yaml:"absentWindows,omitempty"`
+}
+
+// condition returns the condition of the rule and its threshold.
+func (r alertRule) condition() (condition string, threshold float64, err error) {
+	var conditions []string
+	if r.Above != nil {
+		conditions = append(conditions, alertAbove)
+		threshold = float64(*r.Above)
+	}
+	if r.Below != nil {
+		conditions = append(conditions, alertBelow)
+		threshold = float64(*r.Below)
+	}
+	if r.ChangePercent != 0 {
+		conditions = append(conditions, alertChange)
+		threshold = r.ChangePercent
+	}
+	if r.AbsentWindows < 0 {
+		return "", 0, fmt.Errorf("absentWindows must not be negative, got %d", r.AbsentWindows)
+	} else if r.AbsentWindows > 0 {
+		conditions = append(conditions, alertAbsent)
+		threshold = float64(r.AbsentWindows)
+	}
+	if len(conditions) != 1 {
+		return "", 0, fmt.Errorf("rule %q must have exactly one of above, below, changePercent or absentWindows, got %d", r.Name, len(conditions))
+	}
+	return conditions[0], threshold, nil
+}
+
+// alertEvent is a change of the state of an alert, posted as JSON to the webhook.
+type alertEvent struct {
+	Rule          string    `json:"rule"`
+	Value         string    `json:"value"`
+	Status        string    `json:"status"`
+	Condition     string    `json:"condition"`
+	Threshold     float64   `json:"threshold"`
+	Count         uint64    `json:"count"`
+	PreviousCount uint64    `json:"previousCount"`
+	AbsentWindows int       `json:"absentWindows,omitempty"`
+	WindowStart   time.Time `json:"windowStart"`
+	WindowEnd     time.Time `json:"windowEnd"`
+}
+
+type compiledAlertRule struct {
+	alertRule
+	condition string
+	threshold float64
+}
+
+type alertKey struct {
+	rule  string
+	value string
+}
+
+// alertState is the state of a rule for a value, it is dropped once the alert is resolved and no windows are absent.
+type alertState struct {
+	firing bool
+	absent int
+}
+
+// alertEvaluator evaluates the alert rules at every window close and tracks which alerts are firing.
+// It belongs to the processing loop like the counts.
+type alertEvaluator struct {
+	rules  []compiledAlertRule
+	states map[alertKey]*alertState
+}
+
+// newAlertEvaluator compiles the rules. It returns nil if there are no rules.
+func newAlertEvaluator(rules []alertRule) (*alertEvaluator, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var errs []error
+	names := make(map[string]bool)
+	compiled := make([]compiledAlertRule, 0, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			errs = append(errs, fmt.Errorf("alerts.rules[%d]: name must not be empty", i))
+			continue
+		}
+		if names[rule.Name] {
+			errs = append(errs, fmt.Errorf("alerts.rules[%d]: duplicate name %q", i, rule.Name))
+			continue
+		}
+		names[rule.Name] = true
+		condition, threshold, err := rule.condition()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alerts.rules[%d]: %w", i, err))
+			continue
+		}
+		compiled = append(compiled, compiledAlertRule{alertRule: rule, condition: condition, threshold: threshold})
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &alertEvaluator{rules: compiled, states: make(map[alertKey]*alertState)}, nil
+}
+
+// withStates returns a with the states of the rules of previous which did not change, so a reload neither reports
+// their firing alerts again nor forgets them. The states of changed and removed rules are dropped.
+func (a *alertEvaluator) withStates(previous *alertEvaluator) *alertEvaluator {
+	if a == nil || previous == nil {
+		return a
+	}
+	unchanged := make(map[string]bool, len(a.rules))
+	for _, rule := range a.rules {
+		i := slices.IndexFunc(previous.rules, func(r compiledAlertRule) bool { return r.Name == rule.Name })
+		if i >= 0 && reflect.DeepEqual(previous.rules[i], rule) {
+			unchanged[rule.Name] = true
+		}
+	}
+	for key, state := range previous.states {
+		if unchanged[key.rule] {
+			a.states[key] = state
+		}
+	}
+	return a
+}
+
+// evaluate checks the rules against a closed window, the window before it and the values counted so far,
+// and returns the alerts which started firing or were resolved.
+func (a *alertEvaluator) evaluate(window windowStats, previous map[string]uint64, known map[string]uint64) []alertEvent {
+	var events []alertEvent
+	for _, rule := range a.rules {
+		values := []string{rule.Value}
+		if rule.Value == "" {
+			values = make([]string, 0, len(known))
+			for value := range known {
+				values = append(values, value)
+			}
+			slices.Sort(values)
+		}
+
+		for _, value := range values {
+			key := alertKey{rule: rule.Name, value: value}
+			state := a.states[key]
+			if state == nil {
+				state = &alertState{}
+			}
+
+			count, previousCount := window.Counts[value], previous[value]
+			if count == 0 {
+				state.absent++
+			} else {
+				state.absent = 0
+			}
+
+			var firing bool
+			switch rule.condition {
+			case alertAbove:
+				firing = float64(count) > rule.threshold
+			case alertBelow:
+				firing = float64(count) < rule.threshold
+			case alertChange:
+				if previousCount > 0 {
+					change := (float64(count) - float64(previousCount)) / float64(previousCount) * 100
+					firing = rule.threshold > 0 && change >= rule.threshold || rule.threshold < 0 && change <= rule.threshold
+				} else {
+					firing = state.firing
+				}
+			case alertAbsent:
+				firing = float64(state.absent) >= rule.threshold
+			}
+
+			if firing != state.firing {
+				status := alertFiring
+				if !firing {
+					status = alertResolved
+				}
+				events = append(events, alertEvent{
+					Rule:          rule.Name,
+					Value:         value,
+					Status:        status,
+					Condition:     rule.condition,
+					Threshold:     rule.threshold,
+					Count:         count,
+					PreviousCount: previousCount,
+					AbsentWindows: state.absent,
+					WindowStart:   window.Start,
+					WindowEnd:     window.End,
+				})
+				state.firing = firing
+			}
+
+			if state.firing || state.absent > 0 {
+				a.states[key] = state
+			} else {
+				delete(a.states, key)
+			}
+		}
+	}
+	return events
+}
+
+// alertNotifier logs the alert events and posts them to the webhook without blocking the processing loop.
+// The webhook is only accessed by the processing loop, every queued notification carries the webhook it is posted to.
+type alertNotifier struct {
+	url           string
+	timeout       time.Duration
+	client        *http.Client
+	notifications chan alertNotification
+	done          chan struct{}
+}
+
+// alertNotification is an alert event queued for the webhook.
+type alertNotification struct {
+	event   alertEvent
+	url     string
+	timeout time.Duration
+}
+
+// alertQueueSize bounds the events waiting for the webhook, further events are only logged.
+const alertQueueSize = 100
+
+// newAlertNotifier starts posting the events to url, which may be empty to only log them.
+func newAlertNotifier(url string, timeout time.Duration) *alertNotifier {
+	n := &alertNotifier{
+		url:           url,
+		timeout:       timeout,
+		client:        &http.Client{},
+		notifications: make(chan alertNotification, alertQueueSize),
+		done:          make(chan struct{}),
+	}
+	go n.run()
+	return n
+}
+
+// setWebhook changes the webhook of the events notified from now on, queued events are posted to the previous one.
+func (n *alertNotifier) setWebhook(url string, timeout time.Duration) {
+	n.url = url
+	n.timeout = timeout
+}
+
+func (n *alertNotifier) notify(event alertEvent) {
+	attrs := []any{
+		slog.String("rule", event.Rule), slog.String("value", event.Value), slog.String("condition", event.Condition),
+		slog.Float64("threshold", event.Threshold), slog.Uint64("count", event.Count), slog.Uint64("previousCount", event.PreviousCount),
+	}
+	if event.Status == alertFiring {
+		slog.Warn("Alert firing", attrs...)
+	} else {
+		slog.Info("Alert resolved", attrs...)
+	}
+	if n.url == "" {
+		return
+	}
+
+	select {
+	case n.notifications <- alertNotification{event: event, url: n.url, timeout: n.timeout}:
+	default:
+		slog.Error("Alert webhook queue full, dropping the notification", slog.String("rule", event.Rule), slog.String("value", event.Value))
+	}
+}
+
+func (n *alertNotifier) run() {
+	defer close(n.done)
+	for notification := range n.notifications {
+		if err := n.post(notification); err != nil {
+			slog.Error("Failed to post alert to the webhook", slog.String("rule", notification.event.Rule), slog.Any("error", err))
+		}
+	}
+}
+
+func (n *alertNotifier) post(notification alertNotification) error {
+	body, err := json.Marshal(notification.event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notification.timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, notification.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", response.Status)
+	}
+	return nil
+}
+
+// Close posts the queued events. It must only be called once the processing loop stopped.
+func (n *alertNotifier) Close() error {
+	close(n.notifications)
+	<-n.done
+	return nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func uint64Pointer(v uint64) *uint64 {
+	return &v
+}
+
+func TestAlertEvaluator_Evaluate(t *testing.T) {
+	tests := map[string]struct {
+		rule     alertRule
+		windows  []map[string]uint64
+		expected []string
+	}{
+		"Above": {
+			rule:     alertRule{Name: "loud", Value: "checkout", Above: uint64Pointer(10)},
+			windows:  []map[string]uint64{{"checkout": 5}, {"checkout": 11}, {"checkout": 12}, {"checkout": 10}},
+			expected: []string{"1 loud checkout firing", "3 loud checkout resolved"},
+		},
+		"Below": {
+			rule:     alertRule{Name: "quiet", Value: "checkout", Below: uint64Pointer(3)},
+			windows:  []map[string]uint64{{"checkout": 5}, {}, {"checkout": 3}},
+			expected: []string{"1 quiet checkout firing", "2 quiet checkout resolved"},
+		},
+		"ChangeUp": {
+			rule:     alertRule{Name: "spike", Value: "checkout", ChangePercent: 50},
+			windows:  []map[string]uint64{{"checkout": 10}, {"checkout": 14}, {"checkout": 21}, {"checkout": 22}},
+			expected: []string{"2 spike checkout firing", "3 spike checkout resolved"},
+		},
+		"ChangeDown": {
+			rule:     alertRule{Name: "drop", Value: "checkout", ChangePercent: -50},
+			windows:  []map[string]uint64{{"checkout": 10}, {"checkout": 4}, {"checkout": 4}},
+			expected: []string{"1 drop checkout firing", "2 drop checkout resolved"},
+		},
+		"ChangeWithoutPreviousCount": {
+			rule:     alertRule{Name: "spike", Value: "checkout", ChangePercent: 50},
+			windows:  []map[string]uint64{{}, {"checkout": 100}},
+			expected: nil,
+		},
+		"Absent": {
+			rule:     alertRule{Name: "silent", Value: "checkout", AbsentWindows: 2},
+			windows:  []map[string]uint64{{"checkout": 1}, {}, {}, {}, {"checkout": 1}},
+			expected: []string{"2 silent checkout firing", "4 silent checkout resolved"},
+		},
+		"EveryValue": {
+			rule:     alertRule{Name: "loud", Above: uint64Pointer(1)},
+			windows:  []map[string]uint64{{"cart": 2, "checkout": 1}, {"checkout": 3}, {"cart": 1}},
+			expected: []string{"0 loud cart firing", "1 loud cart resolved", "1 loud checkout firing", "2 loud checkout resolved"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			evaluator, err := newAlertEvaluator([]alertRule{test.rule})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			var events []string
+			known := make(map[string]uint64)
+			var previous map[string]uint64
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for i, counts := range test.windows {
+				for value, count := range counts {
+					known[value] += count
+				}
+				window := windowStats{Start: start, End: start.Add(time.Minute), Counts: counts}
+				for _, event := range evaluator.evaluate(window, previous, known) {
+					events = append(events, fmt.Sprintf("%d %s %s %s", i, event.Rule, event.Value, event.Status))
+				}
+				previous = counts
+				start = window.End
+			}
+
+			if !slices.Equal(events, test.expected) {
+				t.Errorf("Expected events %v, got %v", test.expected, events)
+			}
+		})
+	}
+}
+
+func TestAlertEvaluator_WithStates(t *testing.T) {
+	previous, _ := newAlertEvaluator([]alertRule{
+		{Name: "loud", Value: "checkout", Above: uint64Pointer(10)},
+		{Name: "quiet", Value: "checkout", Below: uint64Pointer(20)},
+	})
+	next, _ := newAlertEvaluator([]alertRule{
+		{Name: "loud", Value: "checkout", Above: uint64Pointer(10)},
+		{Name: "quiet", Value: "checkout", Below: uint64Pointer(30)},
+	})
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	evaluate := func(evaluator *alertEvaluator, count uint64) []string {
+		counts := map[string]uint64{"checkout": count}
+		window := windowStats{Start: start, End: start.Add(time.Minute), Counts: counts}
+		start = window.End
+		var events []string
+		for _, event := range evaluator.evaluate(window, nil, counts) {
+			events = append(events, event.Rule+" "+event.Status)
+		}
+		return events
+	}
+
+	if events := evaluate(previous, 15); !slices.Equal(events, []string{"loud firing", "quiet firing"}) {
+		t.Fatalf("Expected both rules to fire, got %v", events)
+	}
+	next = next.withStates(previous)
+	if events := evaluate(next, 15); !slices.Equal(events, []string{"quiet firing"}) {
+		t.Errorf("Expected only the changed rule to fire again, got %v", events)
+	}
+	if events := evaluate(next, 5); !slices.Equal(events, []string{"loud resolved"}) {
+		t.Errorf("Expected the unchanged rule to be resolved, got %v", events)
+	}
+}
+
+func TestNewAlertEvaluator_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		rules         []alertRule
+		expectedError string
+	}{
+		"EmptyName": {
+			rules:         []alertRule{{Above: uint64Pointer(1)}},
+			expectedError: "alerts.rules[0]: name must not be empty",
+		},
+		"DuplicateName": {
+			rules:         []alertRule{{Name: "loud", Above: uint64Pointer(1)}, {Name: "loud", Below: uint64Pointer(1)}},
+			expectedError: "alerts.rules[1]: duplicate name \"loud\"",
+		},
+		"NoCondition": {
+			rules:         []alertRule{{Name: "loud"}},
+			expectedError: "alerts.rules[0]: rule \"loud\" must have exactly one of above, below, changePercent or absentWindows, got 0",
+		},
+		"NegativeAbsentWindows": {
+			rules:         []alertRule{{Name: "silent", AbsentWindows: -1}},
+			expectedError: "alerts.rules[0]: absentWindows must not be negative, got -1",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := newAlertEvaluator(test.rules)
+			if err == nil || err.Error() != test.expectedError {
+				t.Errorf("Expected error '%s', got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestAlertNotifier_Webhook(t *testing.T) {
+	received := make(chan alertEvent, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Expected a JSON POST, got %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		var event alertEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Expected a JSON alert event, got %v", err)
+		}
+		received <- event
+	}))
+	defer webhook.Close()
+
+	notifier := newAlertNotifier(webhook.URL, time.Second)
+	lp := &dash0LogsProcessor{
+		logStats:      map[string]uint64{"checkout": 11},
+		windowStats:   map[string]uint64{"checkout": 11},
+		windowStart:   time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		alertNotifier: notifier,
+	}
+	lp.alerts, _ = newAlertEvaluator([]alertRule{{Name: "loud", Value: "checkout", Above: uint64Pointer(10)}})
+	lp.closeWindow(lp.windowStart.Add(time.Minute))
+	if err := notifier.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	select {
+	case event := <-received:
+		expected := alertEvent{
+			Rule:        "loud",
+			Value:       "checkout",
+			Status:      alertFiring,
+			Condition:   alertAbove,
+			Threshold:   10,
+			Count:       11,
+			WindowStart: lp.lastWindow.Start,
+			WindowEnd:   lp.lastWindow.End,
+		}
+		if event != expected {
+			t.Errorf("Expected event %+v, got %+v", expected, event)
+		}
+	default:
+		t.Errorf("Expected the webhook to receive the alert")
+	}
+}
+
+func TestAlertNotifier_SetWebhook(t *testing.T) {
+	received := make(chan string, 2)
+	webhook := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received <- name
+		}))
+	}
+	first, second := webhook("first"), webhook("second")
+	defer first.Close()
+	defer second.Close()
+
+	notifier := newAlertNotifier(first.URL, time.Second)
+	notifier.notify(alertEvent{Rule: "loud", Value: "checkout", Status: alertFiring})
+	notifier.setWebhook(second.URL, time.Second)
+	notifier.notify(alertEvent{Rule: "loud", Value: "checkout", Status: alertResolved})
+	if err := notifier.Close(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	close(received)
+	var webhooks []string
+	for name := range received {
+		webhooks = append(webhooks, name)
+	}
+	if !slices.Equal(webhooks, []string{"first", "second"}) {
+		t.Errorf("Expected one event per webhook, got %v", webhooks)
+	}
+}
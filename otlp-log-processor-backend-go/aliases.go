@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"gopkg.in/yaml.v3"
+)
+
+// semconvAliases maps the attribute keys of the current semantic conventions to the keys they were renamed from.
+var semconvAliases = map[string][]string{
+	"http.request.method":         {"http.method"},
+	"http.response.status_code":   {"http.status_code"},
+	"http.request.body.size":      {"http.request_content_length"},
+	"http.response.body.size":     {"http.response_content_length"},
+	"url.full":                    {"http.url"},
+	"url.scheme":                  {"http.scheme"},
+	"user_agent.original":         {"http.user_agent"},
+	"client.address":              {"http.client_ip"},
+	"server.address":              {"net.peer.name", "net.host.name"},
+	"server.port":                 {"net.peer.port", "net.host.port"},
+	"network.peer.address":        {"net.sock.peer.addr"},
+	"network.peer.port":           {"net.sock.peer.port"},
+	"network.protocol.name":       {"net.protocol.name"},
+	"network.protocol.version":    {"net.protocol.version", "http.flavor"},
+	"network.transport":           {"net.transport"},
+	"db.query.text":               {"db.statement"},
+	"db.namespace":                {"db.name"},
+	"db.operation.name":           {"db.operation"},
+	"deployment.environment.name": {"deployment.environment"},
+}
+
+// keyAliases maps source attribute keys to the canonical keys they are normalized to.
+type keyAliases map[string]string
+
+// newKeyAliases builds the alias table from the built-in semantic convention renames if semconv is set,
+// and the table read from the alias file. The alias file maps canonical keys to lists of source keys like
+// semconvAliases, its entries take precedence over the built-in ones. It returns nil if there are no aliases.
+func newKeyAliases(semconv bool, file map[string][]string) keyAliases {
+	aliases := make(keyAliases)
+	add := func(table map[string][]string) {
+		for canonical, sources := range table {
+			for _, source := range sources {
+				if source != canonical {
+					aliases[source] = canonical
+				}
+			}
+		}
+	}
+
+	if semconv {
+		add(semconvAliases)
+	}
+	add(file)
+	if len(aliases) == 0 {
+		return nil
+	}
+	return aliases
+}
+
+// readAliasFile reads the table of an alias file, see newKeyAliases.
+func readAliasFile(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading alias file: %w", err)
+	}
+
+	var table map[string][]string
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&table); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("decoding alias file %s: %w", path, err)
+	}
+	return table, nil
+}
+
+// normalize returns attributes with the source keys renamed to their canonical keys. The attributes are copied only
+// if they contain a source key. An attribute already carrying the canonical key wins over its aliases,
+// and among several aliases of the same canonical key the first one wins.
+func (a keyAliases) normalize(attributes []*otelcommon.KeyValue) []*otelcommon.KeyValue {
+	aliased := false
+	for _, kv := range attributes {
+		if _, ok := a[kv.GetKey()]; ok {
+			aliased = true
+			break
+		}
+	}
+	if !aliased {
+		return attributes
+	}
+
+	present := make(map[string]bool, len(attributes))
+	for _, kv := range attributes {
+		if _, ok := a[kv.GetKey()]; !ok {
+			present[kv.GetKey()] = true
+		}
+	}
+	normalized := make([]*otelcommon.KeyValue, 0, len(attributes))
+	for _, kv := range attributes {
+		canonical, ok := a[kv.GetKey()]
+		if !ok {
+			normalized = append(normalized, kv)
+			continue
+		}
+		if present[canonical] {
+			continue
+		}
+		present[canonical] = true
+		normalized = append(normalized, &otelcommon.KeyValue{Key: canonical, Value: kv.GetValue()})
+	}
+	return normalized
+}
+
+// canonicalPath returns path with its attribute key renamed to the canonical key if it is a source key, so configured
+// keys match the normalized attributes. As keys may contain dots, the longest run of plain segments naming a source
+// key is renamed, like resolveKeyValues finds the longest key.
+func (a keyAliases) canonicalPath(path []pathSegment) []pathSegment {
+	if len(a) == 0 || len(path) == 0 || path[0].array {
+		return path
+	}
+
+	n := 1
+	if !path[0].quoted {
+		for n < len(path) && !path[n].array && !path[n].quoted {
+			n++
+		}
+	}
+	for ; n > 0; n-- {
+		keys := make([]string, n)
+		for i, segment := range path[:n] {
+			keys[i] = segment.key
+		}
+		if canonical, ok := a[strings.Join(keys, ".")]; ok {
+			return append([]pathSegment{{key: canonical, quoted: true}}, path[n:]...)
+		}
+	}
+	return path
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func keyValueStrings(attributes []*otelcommon.KeyValue) []string {
+	var keyValues []string
+	for _, kv := range attributes {
+		keyValues = append(keyValues, kv.Key+"="+kv.Value.GetStringValue())
+	}
+	return keyValues
+}
+
+func TestKeyAliases_Normalize(t *testing.T) {
+	aliases := keyAliases{"http.method": "http.request.method", "method": "http.request.method"}
+
+	tests := map[string]struct {
+		attributes []*otelcommon.KeyValue
+		expected   []string
+	}{
+		"NoAlias": {
+			attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "checkout")},
+			expected:   []string{"service.name=checkout"},
+		},
+		"Renamed": {
+			attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "checkout"), stringKeyValue("http.method", "GET")},
+			expected:   []string{"service.name=checkout", "http.request.method=GET"},
+		},
+		"CanonicalWins": {
+			attributes: []*otelcommon.KeyValue{stringKeyValue("http.method", "GET"), stringKeyValue("http.request.method", "POST")},
+			expected:   []string{"http.request.method=POST"},
+		},
+		"FirstAliasWins": {
+			attributes: []*otelcommon.KeyValue{stringKeyValue("method", "PUT"), stringKeyValue("http.method", "GET")},
+			expected:   []string{"http.request.method=PUT"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if got := keyValueStrings(aliases.normalize(test.attributes)); !slices.Equal(got, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestKeyAliases_Normalize_Unchanged(t *testing.T) {
+	attributes := []*otelcommon.KeyValue{stringKeyValue("service.name", "checkout")}
+	if got := keyAliases(nil).normalize(attributes); &got[0] != &attributes[0] {
+		t.Errorf("Expected the attributes not to be copied without aliases")
+	}
+	if got := (keyAliases{"http.method": "http.request.method"}).normalize(attributes); &got[0] != &attributes[0] {
+		t.Errorf("Expected the attributes not to be copied without source keys")
+	}
+}
+
+func TestNewKeyAliases(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "aliases.yaml")
+	content := "service.name: [service_name, serviceName]\nclient.address: [net.peer.name]\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatalf("Writing alias file failed: %v", err)
+	}
+
+	tests := map[string]struct {
+		semconv  bool
+		file     string
+		expected keyAliases
+	}{
+		"None": {},
+		"Semconv": {
+			semconv: true,
+			expected: keyAliases{
+				"http.method":   "http.request.method",
+				"net.peer.name": "server.address",
+			},
+		},
+		"FileOverridesSemconv": {
+			semconv: true,
+			file:    file,
+			expected: keyAliases{
+				"http.method":   "http.request.method",
+				"net.peer.name": "client.address",
+				"service_name":  "service.name",
+				"serviceName":   "service.name",
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var table map[string][]string
+			if test.file != "" {
+				var err error
+				if table, err = readAliasFile(test.file); err != nil {
+					t.Fatalf("readAliasFile failed: %v", err)
+				}
+			}
+			aliases := newKeyAliases(test.semconv, table)
+			if test.expected == nil && aliases != nil {
+				t.Errorf("Expected no aliases, got %v", aliases)
+			}
+			for source, canonical := range test.expected {
+				if aliases[source] != canonical {
+					t.Errorf("Expected %s to be normalized to %s, got '%s'", source, canonical, aliases[source])
+				}
+			}
+		})
+	}
+}
+
+func TestReadAliasFile_Missing(t *testing.T) {
+	if _, err := readAliasFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Expected error for a missing alias file, got nil")
+	}
+}
+
+func TestValueExtractor_Extract_Aliases(t *testing.T) {
+	aliases := newKeyAliases(true, nil)
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			ScopeLogs: []*otellogs.ScopeLogs{{
+				LogRecords: []*otellogs.LogRecord{
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("http.method", "GET")}},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("http.request.method", "POST")}},
+				},
+			}},
+		}},
+	}
+
+	extractor := valueExtractor{attributeKey: "http.request.method", aliases: aliases}
+	values, hits := extractor.extract(context.Background(), request)
+	if !slices.Equal(values, []string{"GET", "POST"}) || hits.logRecord != 2 {
+		t.Errorf("Expected [GET POST] with 2 hits, got %v with %d hits", values, hits.logRecord)
+	}
+}
+
+func TestKeyAliases_CanonicalPath(t *testing.T) {
+	aliases := keyAliases{"http.method": "http.request.method", "labels": "resource.labels"}
+
+	tests := map[string]struct {
+		path     string
+		expected []pathSegment
+	}{
+		"NoAlias": {
+			path:     "service.name",
+			expected: []pathSegment{{key: "service"}, {key: "name"}},
+		},
+		"Renamed": {
+			path:     "http.method",
+			expected: []pathSegment{{key: "http.request.method", quoted: true}},
+		},
+		"RenamedWithinPath": {
+			path:     `labels["team"]`,
+			expected: []pathSegment{{key: "resource.labels", quoted: true}, {key: "team", quoted: true}},
+		},
+		"LongestKeyRenamed": {
+			path:     "labels.team",
+			expected: []pathSegment{{key: "resource.labels", quoted: true}, {key: "team"}},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if got := aliases.canonicalPath(attributePath(test.path)); !slices.Equal(got, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestValueExtractor_Extract_AliasedAttributeKey(t *testing.T) {
+	aliases := newKeyAliases(true, nil)
+	filter, err := newValueFilter([]filterRule{{Key: "http.status_code", Exact: []string{"200"}}}, nil, aliases)
+	if err != nil {
+		t.Fatalf("newValueFilter failed: %v", err)
+	}
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			ScopeLogs: []*otellogs.ScopeLogs{{
+				LogRecords: []*otellogs.LogRecord{
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("http.method", "GET"), stringKeyValue("http.status_code", "200")}},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("http.request.method", "POST"), stringKeyValue("http.response.status_code", "200")}},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("http.method", "PUT"), stringKeyValue("http.status_code", "500")}},
+				},
+			}},
+		}},
+	}
+
+	extractor := valueExtractor{attributeKey: "http.method", aliases: aliases, filter: filter}
+	values, hits := extractor.extract(context.Background(), request)
+	if !slices.Equal(values, []string{"GET", "POST"}) || hits.logRecord != 3 || hits.filtered != 1 {
+		t.Errorf("Expected [GET POST] with 3 hits and 1 filtered, got %v with %d hits and %d filtered", values, hits.logRecord, hits.filtered)
+	}
+}
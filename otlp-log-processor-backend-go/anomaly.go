@@ -0,0 +1,137 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// The direction of an anomaly is recorded in anomalyDirectionAttribute of the anomalies metric.
+const (
+	anomalyDirectionAttribute = "com.dash0.homeexercise.anomaly.direction"
+	anomalyAbove              = "above"
+	anomalyBelow              = "below"
+)
+
+// anomalySettings configure the detection of anomalous counts, a deviations of 0 disables it.
+type anomalySettings struct {
+	deviations    float64
+	alpha         float64
+	warmupWindows int
+	seasonWindows int
+}
+
+// anomaly is a value whose count in a closed window deviates from its baseline by more than the configured deviations.
+type anomaly struct {
+	Value      string  `json:"value"`
+	Count      uint64  `json:"count"`
+	Expected   float64 `json:"expected"`
+	StdDev     float64 `json:"stdDev"`
+	Deviations float64 `json:"deviations"`
+}
+
+func (a anomaly) direction() string {
+	if a.Deviations < 0 {
+		return anomalyBelow
+	}
+	return anomalyAbove
+}
+
+// baseline is the exponentially weighted moving average and variance of the counts of a value.
+type baseline struct {
+	mean     float64
+	variance float64
+	windows  int
+}
+
+func (b *baseline) add(count, alpha float64) {
+	if b.windows == 0 {
+		b.mean = count
+	} else {
+		diff := count - b.mean
+		increment := alpha * diff
+		b.mean += increment
+		b.variance = (1 - alpha) * (b.variance + diff*increment)
+	}
+	b.windows++
+}
+
+// stdDev is at least the standard deviation of a Poisson distributed count with the mean of the baseline,
+// so values with steady counts are not flagged for every small change.
+func (b *baseline) stdDev() float64 {
+	return max(math.Sqrt(b.variance), math.Sqrt(max(b.mean, 1)))
+}
+
+// anomalyDetector keeps a baseline per value, and per position in the season if the counts follow one,
+// and compares the count of every closed window against it. It belongs to the processing loop like the counts.
+type anomalyDetector struct {
+	settings  anomalySettings
+	baselines map[string][]baseline
+	windows   uint64
+}
+
+// newAnomalyDetector returns nil if the detection is disabled.
+func newAnomalyDetector(settings anomalySettings) *anomalyDetector {
+	return (*anomalyDetector)(nil).withSettings(settings)
+}
+
+// withSettings applies changed settings and keeps the baselines unless the season changed.
+func (d *anomalyDetector) withSettings(settings anomalySettings) *anomalyDetector {
+	if settings.deviations <= 0 {
+		return nil
+	}
+	if d == nil || d.settings.seasonWindows != settings.seasonWindows {
+		return &anomalyDetector{settings: settings, baselines: make(map[string][]baseline)}
+	}
+	d.settings = settings
+	return d
+}
+
+// detect compares the counts of a closed window against the baselines of the values counted so far,
+// values missing from the window count 0, and adds the counts to the baselines afterwards.
+func (d *anomalyDetector) detect(counts map[string]uint64, known map[string]uint64) []anomaly {
+	slot := 0
+	if d.settings.seasonWindows > 0 {
+		slot = int(d.windows % uint64(d.settings.seasonWindows))
+	}
+	d.windows++
+
+	var anomalies []anomaly
+	for value := range known {
+		baselines := d.baselines[value]
+		if baselines == nil {
+			baselines = make([]baseline, max(d.settings.seasonWindows, 1))
+			d.baselines[value] = baselines
+		}
+		b := &baselines[slot]
+
+		count := counts[value]
+		if b.windows >= d.settings.warmupWindows {
+			stdDev := b.stdDev()
+			deviations := (float64(count) - b.mean) / stdDev
+			if math.Abs(deviations) > d.settings.deviations {
+				anomalies = append(anomalies, anomaly{
+					Value:      value,
+					Count:      count,
+					Expected:   b.mean,
+					StdDev:     stdDev,
+					Deviations: deviations,
+				})
+			}
+		}
+		b.add(float64(count), d.settings.alpha)
+	}
+
+	slices.SortFunc(anomalies, func(a, b anomaly) int {
+		return cmp.Or(cmp.Compare(math.Abs(b.Deviations), math.Abs(a.Deviations)), strings.Compare(a.Value, b.Value))
+	})
+	for _, a := range anomalies {
+		anomaliesCounter.Add(context.Background(), 1, metric.WithAttributes(attribute.String(anomalyDirectionAttribute, a.direction())))
+	}
+	return anomalies
+}
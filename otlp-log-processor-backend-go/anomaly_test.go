@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestAnomalyDetector_Detect(t *testing.T) {
+	steady := []map[string]uint64{{"checkout": 100}, {"checkout": 110}, {"checkout": 90}, {"checkout": 105}, {"checkout": 95}}
+
+	tests := map[string]struct {
+		settings anomalySettings
+		windows  []map[string]uint64
+		expected []string
+	}{
+		"Steady": {
+			settings: anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 3},
+			windows:  append(slices.Clone(steady), map[string]uint64{"checkout": 104}),
+			expected: nil,
+		},
+		"Spike": {
+			settings: anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 3},
+			windows:  append(slices.Clone(steady), map[string]uint64{"checkout": 300}),
+			expected: []string{"5 checkout above"},
+		},
+		"Absent": {
+			settings: anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 3},
+			windows:  append(slices.Clone(steady), map[string]uint64{"cart": 1}),
+			expected: []string{"5 checkout below"},
+		},
+		"Warmup": {
+			settings: anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 3},
+			windows:  []map[string]uint64{{"checkout": 100}, {"checkout": 100}, {"checkout": 300}},
+			expected: nil,
+		},
+		"StdDevFloor": {
+			settings: anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 3},
+			windows:  []map[string]uint64{{"checkout": 100}, {"checkout": 100}, {"checkout": 100}, {"checkout": 120}},
+			expected: nil,
+		},
+		"WithoutSeason": {
+			settings: anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 2},
+			windows:  []map[string]uint64{{"checkout": 10}, {"checkout": 1000}, {"checkout": 10}, {"checkout": 1000}, {"checkout": 10}, {"checkout": 1000}, {"checkout": 1000}},
+			expected: nil,
+		},
+		"Season": {
+			settings: anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 2, seasonWindows: 2},
+			windows:  []map[string]uint64{{"checkout": 10}, {"checkout": 1000}, {"checkout": 10}, {"checkout": 1000}, {"checkout": 10}, {"checkout": 1000}, {"checkout": 1000}},
+			expected: []string{"6 checkout above"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			detector := newAnomalyDetector(test.settings)
+			known := make(map[string]uint64)
+			var anomalies []string
+			for i, counts := range test.windows {
+				for value, count := range counts {
+					known[value] += count
+				}
+				for _, a := range detector.detect(counts, known) {
+					anomalies = append(anomalies, fmt.Sprintf("%d %s %s", i, a.Value, a.direction()))
+				}
+			}
+
+			if !slices.Equal(anomalies, test.expected) {
+				t.Errorf("Expected anomalies %v, got %v", test.expected, anomalies)
+			}
+		})
+	}
+}
+
+func TestAnomalyDetector_WithSettings(t *testing.T) {
+	settings := anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 1}
+	detector := newAnomalyDetector(settings)
+	detector.detect(map[string]uint64{"checkout": 100}, map[string]uint64{"checkout": 100})
+
+	settings.deviations = 4
+	if next := detector.withSettings(settings); next != detector || len(next.baselines) != 1 || next.settings.deviations != 4 {
+		t.Errorf("Expected the baselines to be kept when the deviations change, got %+v", next)
+	}
+	settings.seasonWindows = 24
+	if next := detector.withSettings(settings); next == detector || len(next.baselines) != 0 {
+		t.Errorf("Expected the baselines to be reset when the season changes, got %+v", next)
+	}
+	settings.deviations = 0
+	if next := detector.withSettings(settings); next != nil {
+		t.Errorf("Expected no detector when the deviations are 0, got %+v", next)
+	}
+}
+
+func TestDash0LogsProcessor_CloseWindow_Anomalies(t *testing.T) {
+	reader := metric.NewManualReader()
+	counter, err := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test").Int64Counter("com.dash0.homeexercise.values.anomalies")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	originalCounter := anomaliesCounter
+	anomaliesCounter = counter
+	defer func() { anomaliesCounter = originalCounter }()
+
+	lp := &dash0LogsProcessor{
+		logStats:    make(map[string]uint64),
+		windowStats: make(map[string]uint64),
+		anomalies:   newAnomalyDetector(anomalySettings{deviations: 3, alpha: 0.3, warmupWindows: 3}),
+	}
+	for _, count := range []int{100, 100, 100, 300} {
+		for range count {
+			lp.count("checkout")
+		}
+		lp.closeWindow(lp.windowStart.Add(time.Minute))
+	}
+
+	stats := lp.stats(lp.windowStart)
+	if len(stats.Anomalies) != 1 || stats.Anomalies[0].Value != "checkout" || stats.Anomalies[0].Count != 300 || stats.Anomalies[0].Expected != 100 {
+		t.Fatalf("Expected an anomaly of checkout with 300 instead of 100, got %+v", stats.Anomalies)
+	}
+
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &resourceMetrics); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	directions := make(map[string]int64)
+	for _, sm := range resourceMetrics.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				if dp.Attributes.Len() != 1 {
+					t.Errorf("Expected only the direction attribute, got %v", dp.Attributes.ToSlice())
+				}
+				direction, _ := dp.Attributes.Value(anomalyDirectionAttribute)
+				directions[direction.AsString()] += dp.Value
+			}
+		}
+	}
+	if len(directions) != 1 || directions["above"] != 1 {
+		t.Errorf("Expected one anomaly above its baseline, got %v", directions)
+	}
+}
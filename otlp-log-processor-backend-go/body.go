@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// bodyKey is the attribute key addressing the log record body, "body.<path>" addresses a field within the body.
+const bodyKey = "body"
+
+// bodyPath returns the path within the body addressed by the path of the attribute key, the empty path being the
+// whole body. ok is false if the path does not address the body.
+func bodyPath(path []pathSegment) (rest []pathSegment, ok bool) {
+	if len(path) == 0 || path[0].array || path[0].quoted || path[0].key != bodyKey {
+		return nil, false
+	}
+	return path[1:], true
+}
+
+// parseJSON converts a JSON object or array into a kvlist or array value, with the keys sorted.
+func parseJSON(text string) (*otelcommon.AnyValue, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "{") && !strings.HasPrefix(text, "[") {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, false
+	}
+	return jsonAnyValue(value), true
+}
+
+func jsonAnyValue(value any) *otelcommon.AnyValue {
+	switch v := value.(type) {
+	case string:
+		return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_StringValue{StringValue: v}}
+	case bool:
+		return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_BoolValue{BoolValue: v}}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: i}}
+		}
+		f, _ := v.Float64()
+		return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: f}}
+	case []any:
+		values := make([]*otelcommon.AnyValue, len(v))
+		for i, elem := range v {
+			values[i] = jsonAnyValue(elem)
+		}
+		return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_ArrayValue{ArrayValue: &otelcommon.ArrayValue{Values: values}}}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		values := make([]*otelcommon.KeyValue, len(keys))
+		for i, key := range keys {
+			values[i] = &otelcommon.KeyValue{Key: key, Value: jsonAnyValue(v[key])}
+		}
+		return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_KvlistValue{KvlistValue: &otelcommon.KeyValueList{Values: values}}}
+	default:
+		return &otelcommon.AnyValue{}
+	}
+}
+
+// newBodyPattern compiles the pattern extracting the value from a string body.
+// The value is the capture group named "value", or the first capture group.
+func newBodyPattern(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if pattern.NumSubexp() == 0 {
+		return nil, fmt.Errorf("pattern %q has no capture group", expr)
+	}
+	return pattern, nil
+}
+
+// matchBodyPattern returns the capture group of pattern within value, which must be a string.
+func matchBodyPattern(value *otelcommon.AnyValue, pattern *regexp.Regexp) (string, bool) {
+	text, isString := value.GetValue().(*otelcommon.AnyValue_StringValue)
+	if !isString {
+		return "", false
+	}
+	match := pattern.FindStringSubmatch(text.StringValue)
+	if match == nil {
+		return "", false
+	}
+	group := 1
+	if named := pattern.SubexpIndex("value"); named > 0 {
+		group = named
+	}
+	return match[group], true
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func stringBody(text string) *otelcommon.AnyValue {
+	return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_StringValue{StringValue: text}}
+}
+
+func kvlistBody(values ...*otelcommon.KeyValue) *otelcommon.AnyValue {
+	return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_KvlistValue{KvlistValue: &otelcommon.KeyValueList{Values: values}}}
+}
+
+func TestBodyPath(t *testing.T) {
+	tests := map[string]struct {
+		key          string
+		expectedPath int
+		expectedOK   bool
+	}{
+		"Body":         {key: "body", expectedPath: 0, expectedOK: true},
+		"BodyField":    {key: "body.http.status", expectedPath: 2, expectedOK: true},
+		"BodyIndex":    {key: "body[0]", expectedPath: 1, expectedOK: true},
+		"QuotedBody":   {key: `["body"]`, expectedOK: false},
+		"Attribute":    {key: "service.name", expectedOK: false},
+		"BodyLikeKey":  {key: "bodysize", expectedOK: false},
+		"NestedInBody": {key: "http.body", expectedOK: false},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path, ok := bodyPath(attributePath(test.key))
+			if ok != test.expectedOK || len(path) != test.expectedPath {
+				t.Errorf("Expected (%d segments, %t), got (%v, %t)", test.expectedPath, test.expectedOK, path, ok)
+			}
+		})
+	}
+}
+
+func TestValueExtractor_RecordValues_Body(t *testing.T) {
+	kvlist := kvlistBody(
+		&otelcommon.KeyValue{Key: "http", Value: kvlistBody(
+			&otelcommon.KeyValue{Key: "status", Value: &otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: 503}}},
+		)},
+		stringKeyValue("http.route", "/checkout"),
+		stringKeyValue("payload", `{"order": {"id": "o-1"}}`),
+	)
+
+	tests := map[string]struct {
+		body       *otelcommon.AnyValue
+		key        string
+		pattern    string
+		expected   string
+		expectedOK bool
+	}{
+		"WholeStringBody": {
+			body:       stringBody("connection refused"),
+			key:        "body",
+			expected:   "connection refused",
+			expectedOK: true,
+		},
+		"KvlistNested": {
+			body:       kvlist,
+			key:        "body.http.status",
+			expected:   "503",
+			expectedOK: true,
+		},
+		"KvlistDottedKey": {
+			body:       kvlist,
+			key:        "body.http.route",
+			expected:   "/checkout",
+			expectedOK: true,
+		},
+		"KvlistJSONString": {
+			body:       kvlist,
+			key:        "body.payload.order.id",
+			expected:   "o-1",
+			expectedOK: true,
+		},
+		"KvlistMissing": {
+			body: kvlist,
+			key:  "body.http.method",
+		},
+		"JSONString": {
+			body:       stringBody(`{"level": "error", "http": {"status": 404, "latency": 0.5}}`),
+			key:        "body.http.status",
+			expected:   "404",
+			expectedOK: true,
+		},
+		"JSONObject": {
+			body:       stringBody(`{"http": {"status": 404, "ok": false}}`),
+			key:        "body.http",
+			expected:   "{ok:false,status:404}",
+			expectedOK: true,
+		},
+		"PlainTextPath": {
+			body: stringBody("status=500"),
+			key:  "body.status",
+		},
+		"NoBody": {
+			body: nil,
+			key:  "body",
+		},
+		"Pattern": {
+			body:       stringBody("GET /checkout status=500 took 3ms"),
+			key:        "body",
+			pattern:    `status=(\d+)`,
+			expected:   "500",
+			expectedOK: true,
+		},
+		"NamedPattern": {
+			body:       stringBody("GET /checkout status=500"),
+			key:        "body",
+			pattern:    `(GET|POST) (?P<value>/\S+)`,
+			expected:   "/checkout",
+			expectedOK: true,
+		},
+		"PatternOnField": {
+			body:       stringBody(`{"message": "user=alice logged in"}`),
+			key:        "body.message",
+			pattern:    `user=(\w+)`,
+			expected:   "alice",
+			expectedOK: true,
+		},
+		"PatternMismatch": {
+			key:     "body",
+			body:    stringBody("healthy"),
+			pattern: `status=(\d+)`,
+		},
+		"PatternOnNonString": {
+			body:    kvlist,
+			key:     "body.http.status",
+			pattern: `(\d+)`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			pattern, err := newBodyPattern(test.pattern)
+			if err != nil {
+				t.Fatalf("newBodyPattern failed: %v", err)
+			}
+			extractor := valueExtractor{path: attributePath(test.key), bodyPattern: pattern}
+			values, _ := extractor.recordValues(nil, &otellogs.LogRecord{Body: test.body})
+			if ok := values != nil; ok != test.expectedOK || ok && values[0].text != test.expected {
+				t.Errorf("Expected ('%s', %t), got %v", test.expected, test.expectedOK, values)
+			}
+		})
+	}
+}
+
+func TestNewBodyPattern_NoCaptureGroup(t *testing.T) {
+	if _, err := newBodyPattern(`status=\d+`); err == nil {
+		t.Errorf("Expected error for a pattern without capture group, got nil")
+	}
+}
+
+func TestValueExtractor_Body(t *testing.T) {
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{
+			{
+				ScopeLogs: []*otellogs.ScopeLogs{
+					{
+						LogRecords: []*otellogs.LogRecord{
+							{Body: stringBody(`{"http": {"status": 200}}`)},
+							{Body: kvlistBody(&otelcommon.KeyValue{Key: "http", Value: kvlistBody(
+								&otelcommon.KeyValue{Key: "status", Value: &otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: 500}}},
+							)})},
+							{Body: stringBody("not json")},
+							{},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	values, hits := valueExtractor{attributeKey: "body.http.status"}.extract(context.Background(), request)
+	if !slices.Equal(values, []string{"200", "500"}) {
+		t.Errorf("Expected [200 500], got %v", values)
+	}
+	if hits.logRecord != 2 {
+		t.Errorf("Expected 2 log record hits, got %d", hits.logRecord)
+	}
+}
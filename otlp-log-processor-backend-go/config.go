@@ -0,0 +1,746 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config holds all settings of the processor.
+// The settings are taken from the CLI flags, the OTLP_PROCESSOR_* environment variables, the config file and the defaults,
+// in that order of precedence.
+type config struct {
+	Listen    listenConfig    `yaml:"listen"`
+	Admin     adminConfig     `yaml:"admin"`
+	Attribute attributeConfig `yaml:"attribute"`
+	Window    windowConfig    `yaml:"window"`
+	Intake    intakeConfig    `yaml:"intake"`
+	RateLimit rateLimitConfig `yaml:"rateLimit"`
+	Snapshot  snapshotConfig  `yaml:"snapshot"`
+	WAL       walConfig       `yaml:"wal"`
+	Shutdown  shutdownConfig  `yaml:"shutdown"`
+	Filter    filterConfig    `yaml:"filter"`
+	Severity  severityConfig  `yaml:"severity"`
+	Forward   forwardConfig   `yaml:"forward"`
+	Values    valuesConfig    `yaml:"values"`
+	Anomaly   anomalyConfig   `yaml:"anomaly"`
+	Alerts    alertsConfig    `yaml:"alerts"`
+
+	// Transforms can only be set in the config file, like the filter rules.
+	Transforms []transformRule `yaml:"transforms,omitempty"`
+
+	path          string
+	watchInterval time.Duration
+	printConfig   bool
+
+	// aliasTable holds the contents of the alias file, so a reload notices when only the file changed.
+	aliasTable map[string][]string
+
+	// sources records for every flag name where its value came from.
+	sources map[string]string
+}
+
+type listenConfig struct {
+	Addr                  string `yaml:"addr"`
+	MaxReceiveMessageSize int    `yaml:"maxReceiveMessageSize"`
+	Reflection            bool   `yaml:"reflection"`
+}
+
+type adminConfig struct {
+	Addr string `yaml:"addr"`
+}
+
+type attributeConfig struct {
+	Key             string `yaml:"key"`
+	KeyMatch        string `yaml:"keyMatch"`
+	CountMatchedKey bool   `yaml:"countMatchedKey"`
+	SemconvAliases  bool   `yaml:"semconvAliases"`
+	AliasFile       string `yaml:"aliasFile"`
+	BodyPattern     string `yaml:"bodyPattern"`
+	ExplodeArrays   bool   `yaml:"explodeArrays"`
+	Rendering       string `yaml:"rendering"`
+	Typed           bool   `yaml:"typed"`
+}
+
+type windowConfig struct {
+	Duration time.Duration `yaml:"duration"`
+}
+
+type intakeConfig struct {
+	BufferSize   uint          `yaml:"bufferSize"`
+	FullDuration time.Duration `yaml:"fullDuration"`
+}
+
+type rateLimitConfig struct {
+	RecordsPerSecond float64 `yaml:"recordsPerSecond"`
+	Burst            int     `yaml:"burst"`
+	TenantHeader     string  `yaml:"tenantHeader"`
+}
+
+type snapshotConfig struct {
+	File     string        `yaml:"file"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+type walConfig struct {
+	Dir                string        `yaml:"dir"`
+	Fsync              string        `yaml:"fsync"`
+	CheckpointInterval time.Duration `yaml:"checkpointInterval"`
+}
+
+type shutdownConfig struct {
+	DrainDelay time.Duration `yaml:"drainDelay"`
+}
+
+type severityConfig struct {
+	Breakdown bool   `yaml:"breakdown"`
+	Min       string `yaml:"min"`
+}
+
+// forwardConfig holds the settings of forwarding the accepted requests to a downstream OTLP endpoint.
+type forwardConfig struct {
+	Endpoint       string                `yaml:"endpoint"`
+	Protocol       string                `yaml:"protocol"`
+	Insecure       bool                  `yaml:"insecure"`
+	Timeout        time.Duration         `yaml:"timeout"`
+	QueueSize      int                   `yaml:"queueSize"`
+	BatchSize      int                   `yaml:"batchSize"`
+	BatchTimeout   time.Duration         `yaml:"batchTimeout"`
+	Retry          forwardRetryConfig    `yaml:"retry"`
+	CountAttribute string                `yaml:"countAttribute"`
+	Sampling       forwardSamplingConfig `yaml:"sampling"`
+}
+
+type forwardRetryConfig struct {
+	InitialInterval time.Duration `yaml:"initialInterval"`
+	MaxInterval     time.Duration `yaml:"maxInterval"`
+	MaxElapsedTime  time.Duration `yaml:"maxElapsedTime"`
+}
+
+type forwardSamplingConfig struct {
+	TargetPerWindow      int    `yaml:"targetPerWindow"`
+	ProbabilityAttribute string `yaml:"probabilityAttribute"`
+}
+
+// valuesConfig holds the settings of tracking when the values were seen.
+type valuesConfig struct {
+	StaleAfter time.Duration `yaml:"staleAfter"`
+}
+
+// anomalyConfig holds the settings of detecting values whose count deviates from their baseline.
+type anomalyConfig struct {
+	Deviations    float64 `yaml:"deviations"`
+	Alpha         float64 `yaml:"alpha"`
+	WarmupWindows int     `yaml:"warmupWindows"`
+	SeasonWindows int     `yaml:"seasonWindows"`
+}
+
+func (c anomalyConfig) settings() anomalySettings {
+	return anomalySettings{
+		deviations:    c.Deviations,
+		alpha:         c.Alpha,
+		warmupWindows: c.WarmupWindows,
+		seasonWindows: c.SeasonWindows,
+	}
+}
+
+// alertsConfig holds the alert rules and the webhook notified about them. The rules can only be set in the config file.
+type alertsConfig struct {
+	Webhook alertWebhookConfig `yaml:"webhook"`
+	Rules   []alertRule        `yaml:"rules,omitempty"`
+}
+
+type alertWebhookConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// filterConfig holds the rules deciding which values are counted. The rules can only be set in the config file.
+type filterConfig struct {
+	Include []filterRule `yaml:"include,omitempty"`
+	Exclude []filterRule `yaml:"exclude,omitempty"`
+}
+
+// envPrefix is prepended to the upper snake case flag names to form the environment variables,
+// e.g. OTLP_PROCESSOR_ATTRIBUTE_KEY for -attributeKey.
+const envPrefix = "OTLP_PROCESSOR_"
+
+// settingFlags maps the settings of the config file to the CLI flags setting them.
+var settingFlags = map[string]string{
+	"listen.addr":                           "listenAddr",
+	"listen.maxReceiveMessageSize":          "maxReceiveMessageSize",
+	"listen.reflection":                     "reflection",
+	"admin.addr":                            "adminAddr",
+	"attribute.key":                         "attributeKey",
+	"attribute.keyMatch":                    "attributeKeyMatch",
+	"attribute.countMatchedKey":             "countMatchedKey",
+	"attribute.semconvAliases":              "semconvAliases",
+	"attribute.aliasFile":                   "aliasFile",
+	"attribute.bodyPattern":                 "bodyPattern",
+	"attribute.explodeArrays":               "explodeArrays",
+	"attribute.rendering":                   "valueRendering",
+	"attribute.typed":                       "typedValues",
+	"severity.breakdown":                    "severityBreakdown",
+	"severity.min":                          "minSeverity",
+	"window.duration":                       "duration",
+	"intake.bufferSize":                     "bufferSize",
+	"intake.fullDuration":                   "intakeFullDuration",
+	"rateLimit.recordsPerSecond":            "rateLimit",
+	"rateLimit.burst":                       "rateLimitBurst",
+	"rateLimit.tenantHeader":                "rateLimitTenantHeader",
+	"snapshot.file":                         "snapshotFile",
+	"snapshot.interval":                     "snapshotInterval",
+	"wal.dir":                               "walDir",
+	"wal.fsync":                             "walFsync",
+	"wal.checkpointInterval":                "walCheckpointInterval",
+	"shutdown.drainDelay":                   "drainDelay",
+	"forward.endpoint":                      "forwardEndpoint",
+	"forward.protocol":                      "forwardProtocol",
+	"forward.insecure":                      "forwardInsecure",
+	"forward.timeout":                       "forwardTimeout",
+	"forward.queueSize":                     "forwardQueueSize",
+	"forward.batchSize":                     "forwardBatchSize",
+	"forward.batchTimeout":                  "forwardBatchTimeout",
+	"forward.retry.initialInterval":         "forwardRetryInitialInterval",
+	"forward.retry.maxInterval":             "forwardRetryMaxInterval",
+	"forward.retry.maxElapsedTime":          "forwardRetryMaxElapsedTime",
+	"forward.countAttribute":                "forwardCountAttribute",
+	"forward.sampling.targetPerWindow":      "forwardSamplingTarget",
+	"forward.sampling.probabilityAttribute": "forwardSamplingAttribute",
+	"values.staleAfter":                     "staleAfter",
+	"anomaly.deviations":                    "anomalyDeviations",
+	"anomaly.alpha":                         "anomalyAlpha",
+	"anomaly.warmupWindows":                 "anomalyWarmupWindows",
+	"anomaly.seasonWindows":                 "anomalySeasonWindows",
+	"alerts.webhook.url":                    "alertWebhookURL",
+	"alerts.webhook.timeout":                "alertWebhookTimeout",
+}
+
+func defaultConfig() *config {
+	return &config{
+		Listen: listenConfig{
+			Addr:                  "localhost:4317",
+			MaxReceiveMessageSize: 16777216,
+		},
+		Attribute: attributeConfig{Key: "service.name", KeyMatch: keyMatchExact, Rendering: renderingLegacy},
+		Window:    windowConfig{Duration: time.Second * 10},
+		Intake: intakeConfig{
+			BufferSize:   1000,
+			FullDuration: time.Second * 5,
+		},
+		Snapshot: snapshotConfig{Interval: time.Second * 30},
+		WAL: walConfig{
+			Fsync:              walFsyncInterval,
+			CheckpointInterval: time.Second,
+		},
+		Forward: forwardConfig{
+			Protocol:     otlpProtocolGRPC,
+			Timeout:      time.Second * 10,
+			QueueSize:    1000,
+			BatchSize:    512,
+			BatchTimeout: time.Second,
+			Retry: forwardRetryConfig{
+				InitialInterval: time.Millisecond * 500,
+				MaxInterval:     time.Second * 30,
+				MaxElapsedTime:  time.Minute * 5,
+			},
+			Sampling: forwardSamplingConfig{ProbabilityAttribute: "sampling.probability"},
+		},
+		Values: valuesConfig{StaleAfter: time.Hour},
+		Anomaly: anomalyConfig{
+			Alpha:         0.3,
+			WarmupWindows: 5,
+		},
+		Alerts: alertsConfig{
+			Webhook: alertWebhookConfig{Timeout: time.Second * 5},
+		},
+		watchInterval: time.Second * 5,
+	}
+}
+
+// registerFlags binds the CLI flags to the fields of c, using the current values as defaults.
+func (c *config) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "config", c.path, "The YAML or JSON config file, flags and environment variables take precedence over its settings")
+	fs.BoolVar(&c.printConfig, "print-config", c.printConfig, "Print the effective configuration with the source of each setting and exit")
+	fs.DurationVar(&c.watchInterval, "configWatchInterval", c.watchInterval, "The duration between checks of the config file for changes, 0 disables watching")
+	fs.StringVar(&c.Listen.Addr, "listenAddr", c.Listen.Addr, "The listen address")
+	fs.IntVar(&c.Listen.MaxReceiveMessageSize, "maxReceiveMessageSize", c.Listen.MaxReceiveMessageSize, "The max message size in bytes the server can receive")
+	fs.BoolVar(&c.Listen.Reflection, "reflection", c.Listen.Reflection, "Register the gRPC server reflection service")
+	fs.StringVar(&c.Admin.Addr, "adminAddr", c.Admin.Addr, "The listen address of the admin HTTP API, empty disables the admin API")
+	fs.StringVar(&c.Attribute.Key, "attributeKey", c.Attribute.Key, "The attributeKey to count, a path like key.nested[\"dotted.key\"][0] descends into kvlist and array values, body or body.<path> count the log record body or a field within it")
+	fs.StringVar(&c.Attribute.KeyMatch, "attributeKeyMatch", c.Attribute.KeyMatch, "How the attributeKey is matched against the attribute keys: exact, glob or regex, glob and regex match any key of the resource, scope and log record attributes")
+	fs.BoolVar(&c.Attribute.CountMatchedKey, "countMatchedKey", c.Attribute.CountMatchedKey, "Count matched_key=value instead of the value alone for glob and regex attribute keys")
+	fs.BoolVar(&c.Attribute.SemconvAliases, "semconvAliases", c.Attribute.SemconvAliases, "Normalize attribute keys renamed by the semantic conventions to their current keys, e.g. http.method to http.request.method")
+	fs.StringVar(&c.Attribute.AliasFile, "aliasFile", c.Attribute.AliasFile, "The YAML file mapping canonical attribute keys to lists of keys normalized to them, taking precedence over the semantic convention aliases")
+	fs.BoolVar(&c.Attribute.ExplodeArrays, "explodeArrays", c.Attribute.ExplodeArrays, "Count every element of array values separately")
+	fs.StringVar(&c.Attribute.Rendering, "valueRendering", c.Attribute.Rendering, "How values are converted to strings: legacy, or canonical with shortest round-trip doubles, base64 bytes and JSON arrays and kvlists")
+	fs.BoolVar(&c.Attribute.Typed, "typedValues", c.Attribute.Typed, "Prefix the values with their type, e.g. int:42 and string:42")
+	fs.StringVar(&c.Attribute.BodyPattern, "bodyPattern", c.Attribute.BodyPattern, "The regular expression extracting the value from string bodies by its capture group named value, or its first capture group")
+	fs.BoolVar(&c.Severity.Breakdown, "severityBreakdown", c.Severity.Breakdown, "Count the values per severity range of their log records")
+	fs.StringVar(&c.Severity.Min, "minSeverity", c.Severity.Min, "Count only log records at or above this severity: TRACE, DEBUG, INFO, WARN, ERROR or FATAL")
+	fs.DurationVar(&c.Window.Duration, "duration", c.Window.Duration, "The duration between the output of the stats of the attributeKey")
+	fs.UintVar(&c.Intake.BufferSize, "bufferSize", c.Intake.BufferSize, "The size of the buffer for log ingestion")
+	fs.DurationVar(&c.Intake.FullDuration, "intakeFullDuration", c.Intake.FullDuration, "The duration the log intake may stay full before the health status is NOT_SERVING, 0 disables the check")
+	fs.Float64Var(&c.RateLimit.RecordsPerSecond, "rateLimit", c.RateLimit.RecordsPerSecond, "The log records per second each client may export, 0 disables rate limiting")
+	fs.IntVar(&c.RateLimit.Burst, "rateLimitBurst", c.RateLimit.Burst, "The log records a client may export at once, defaults to the rate limit but at least 8192, larger exports are admitted with a full bucket")
+	fs.StringVar(&c.RateLimit.TenantHeader, "rateLimitTenantHeader", c.RateLimit.TenantHeader, "The gRPC metadata header identifying the tenant to rate limit, the peer address is used if unset")
+	fs.StringVar(&c.Snapshot.File, "snapshotFile", c.Snapshot.File, "The file to persist the counts to, empty disables snapshots")
+	fs.DurationVar(&c.Snapshot.Interval, "snapshotInterval", c.Snapshot.Interval, "The duration between snapshots of the counts")
+	fs.StringVar(&c.WAL.Dir, "walDir", c.WAL.Dir, "The directory of the write-ahead log for accepted log values, empty disables the write-ahead log")
+	fs.StringVar(&c.WAL.Fsync, "walFsync", c.WAL.Fsync, "When to fsync the write-ahead log: always, interval (every second) or never")
+	fs.DurationVar(&c.WAL.CheckpointInterval, "walCheckpointInterval", c.WAL.CheckpointInterval, "The duration between checkpoints of the write-ahead log if snapshots are disabled")
+	fs.DurationVar(&c.Shutdown.DrainDelay, "drainDelay", c.Shutdown.DrainDelay, "The duration to report NOT_SERVING before stopping the gRPC server on shutdown")
+	fs.StringVar(&c.Forward.Endpoint, "forwardEndpoint", c.Forward.Endpoint, "The OTLP endpoint the accepted requests are forwarded to, host:port for grpc or a URL for http/protobuf, empty disables forwarding")
+	fs.StringVar(&c.Forward.Protocol, "forwardProtocol", c.Forward.Protocol, "The protocol of the forward endpoint: grpc or http/protobuf")
+	fs.BoolVar(&c.Forward.Insecure, "forwardInsecure", c.Forward.Insecure, "Connect to the forward endpoint without TLS")
+	fs.DurationVar(&c.Forward.Timeout, "forwardTimeout", c.Forward.Timeout, "The timeout of a single forward export, and of flushing the forward queue on shutdown")
+	fs.IntVar(&c.Forward.QueueSize, "forwardQueueSize", c.Forward.QueueSize, "The number of requests waiting to be forwarded, further requests are dropped from forwarding")
+	fs.IntVar(&c.Forward.BatchSize, "forwardBatchSize", c.Forward.BatchSize, "The number of log records which are forwarded at once")
+	fs.DurationVar(&c.Forward.BatchTimeout, "forwardBatchTimeout", c.Forward.BatchTimeout, "The duration a request waits for further requests to fill its batch")
+	fs.DurationVar(&c.Forward.Retry.InitialInterval, "forwardRetryInitialInterval", c.Forward.Retry.InitialInterval, "The wait before the first retry of a failed forward export, doubled for every further retry")
+	fs.DurationVar(&c.Forward.Retry.MaxInterval, "forwardRetryMaxInterval", c.Forward.Retry.MaxInterval, "The maximum wait between retries of a failed forward export")
+	fs.StringVar(&c.Forward.CountAttribute, "forwardCountAttribute", c.Forward.CountAttribute, "The attribute annotating every forwarded log record with the running count of its value in the current window, empty disables it")
+	fs.IntVar(&c.Forward.Sampling.TargetPerWindow, "forwardSamplingTarget", c.Forward.Sampling.TargetPerWindow, "The number of log records per value and window which are forwarded, louder values are sampled down to it, 0 disables sampling")
+	fs.StringVar(&c.Forward.Sampling.ProbabilityAttribute, "forwardSamplingAttribute", c.Forward.Sampling.ProbabilityAttribute, "The attribute recording the sampling probability of the forwarded log records, empty omits it")
+	fs.DurationVar(&c.Forward.Retry.MaxElapsedTime, "forwardRetryMaxElapsedTime", c.Forward.Retry.MaxElapsedTime, "The duration after which a failed forward export is dropped, 0 disables retries")
+	fs.DurationVar(&c.Values.StaleAfter, "staleAfter", c.Values.StaleAfter, "The duration after which a value which was not counted anymore is stale, 0 disables stale values")
+	fs.Float64Var(&c.Anomaly.Deviations, "anomalyDeviations", c.Anomaly.Deviations, "The number of standard deviations from its baseline at which the count of a value is an anomaly, 0 disables anomaly detection")
+	fs.Float64Var(&c.Anomaly.Alpha, "anomalyAlpha", c.Anomaly.Alpha, "The weight of the last window in the moving average and variance of the baseline, between 0 and 1")
+	fs.IntVar(&c.Anomaly.WarmupWindows, "anomalyWarmupWindows", c.Anomaly.WarmupWindows, "The number of windows which build the baseline of a value before it is checked for anomalies")
+	fs.IntVar(&c.Anomaly.SeasonWindows, "anomalySeasonWindows", c.Anomaly.SeasonWindows, "The number of windows in a season, e.g. a day, with a baseline per window of the season, 0 uses a single baseline")
+	fs.StringVar(&c.Alerts.Webhook.URL, "alertWebhookURL", c.Alerts.Webhook.URL, "The URL the firing and resolved alerts are posted to as JSON, empty only logs them")
+	fs.DurationVar(&c.Alerts.Webhook.Timeout, "alertWebhookTimeout", c.Alerts.Webhook.Timeout, "The timeout of posting an alert to the webhook")
+}
+
+// loadConfig builds the configuration from the CLI flags, the environment variables, the config file and the defaults,
+// in that order of precedence. Every flag can be set by an environment variable named after it, see envVar.
+// The flags are parsed twice: first to find the config file, then again to override the settings of the file and the
+// environment.
+func loadConfig(args []string, errorHandling flag.ErrorHandling) (*config, error) {
+	cfg := defaultConfig()
+	fs := flag.NewFlagSet(os.Args[0], errorHandling)
+	cfg.registerFlags(fs)
+	if errorHandling == flag.ContinueOnError {
+		fs.SetOutput(io.Discard)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nEvery flag can also be set by an environment variable, e.g. %s for -attributeKey.\n", envVar("attributeKey"))
+	}
+
+	cfg.sources = make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		cfg.sources[f.Name] = "default"
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if path, ok := os.LookupEnv(envVar("config")); ok && !explicit["config"] {
+		cfg.path = path
+		cfg.sources["config"] = "env " + envVar("config")
+	}
+	if cfg.path != "" {
+		before := flagValues(fs)
+		if err := cfg.loadFile(cfg.path); err != nil {
+			return nil, err
+		}
+		for name, value := range flagValues(fs) {
+			if value != before[name] {
+				cfg.sources[name] = "config file " + cfg.path
+			}
+		}
+	}
+
+	var errs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(envVar(f.Name))
+		if !ok || f.Name == "config" {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			errs = append(errs, fmt.Errorf("env %s: invalid value %q: %w", envVar(f.Name), value, err))
+			return
+		}
+		cfg.sources[f.Name] = "env " + envVar(f.Name)
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	for name := range explicit {
+		cfg.sources[name] = "flag -" + name
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Attribute.AliasFile != "" {
+		table, err := readAliasFile(cfg.Attribute.AliasFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.aliasTable = table
+	}
+	return cfg, nil
+}
+
+// envVar returns the environment variable of a flag, e.g. OTLP_PROCESSOR_MAX_RECEIVE_MESSAGE_SIZE
+// for -maxReceiveMessageSize. A run of capitals is one word, e.g. OTLP_PROCESSOR_ALERT_WEBHOOK_URL for -alertWebhookURL.
+func envVar(flagName string) string {
+	var name strings.Builder
+	name.WriteString(envPrefix)
+	runes := []rune(flagName)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '.':
+			name.WriteByte('_')
+		case unicode.IsUpper(r):
+			// A capital starts a word after a lower case letter, or ends a run of capitals before a lower case letter.
+			if i > 0 && (!unicode.IsUpper(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				name.WriteByte('_')
+			}
+			name.WriteRune(r)
+		default:
+			name.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return name.String()
+}
+
+func flagValues(fs *flag.FlagSet) map[string]string {
+	values := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values
+}
+
+// loadFile decodes the config file into c. Unknown keys are rejected to catch typos.
+// As YAML is a superset of JSON, JSON config files are supported as well.
+func (c *config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("decoding config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *config) validate() error {
+	var errs []error
+	invalid := func(setting string, format string, args ...any) {
+		err := fmt.Errorf(setting+" "+format, args...)
+		if source, ok := c.sources[cmp.Or(settingFlags[setting], setting)]; ok {
+			err = fmt.Errorf("%w (set by %s)", err, source)
+		}
+		errs = append(errs, err)
+	}
+
+	if c.Listen.Addr == "" {
+		invalid("listen.addr", "must not be empty")
+	}
+	if c.Listen.MaxReceiveMessageSize <= 0 {
+		invalid("listen.maxReceiveMessageSize", "must be positive, got %d", c.Listen.MaxReceiveMessageSize)
+	}
+	if c.Attribute.Key == "" {
+		invalid("attribute.key", "must not be empty")
+	}
+	switch c.Attribute.KeyMatch {
+	case keyMatchExact:
+		if _, err := parseAttributePath(c.Attribute.Key); c.Attribute.Key != "" && err != nil {
+			invalid("attribute.key", "%v", err)
+		}
+	case keyMatchGlob, keyMatchRegex:
+		if _, err := newKeyPattern(c.Attribute.Key, c.Attribute.KeyMatch); err != nil {
+			invalid("attribute.key", "%v", err)
+		}
+	default:
+		invalid("attribute.keyMatch", "must be %s, %s or %s, got %q", keyMatchExact, keyMatchGlob, keyMatchRegex, c.Attribute.KeyMatch)
+	}
+	if c.Window.Duration <= 0 {
+		invalid("window.duration", "must be positive, got %s", c.Window.Duration)
+	}
+	if c.Intake.FullDuration < 0 {
+		invalid("intake.fullDuration", "must not be negative, got %s", c.Intake.FullDuration)
+	}
+	if c.RateLimit.RecordsPerSecond < 0 {
+		invalid("rateLimit.recordsPerSecond", "must not be negative, got %g", c.RateLimit.RecordsPerSecond)
+	}
+	if c.RateLimit.Burst < 0 {
+		invalid("rateLimit.burst", "must not be negative, got %d", c.RateLimit.Burst)
+	}
+	if c.Snapshot.File != "" && c.Snapshot.Interval <= 0 {
+		invalid("snapshot.interval", "must be positive, got %s", c.Snapshot.Interval)
+	}
+	if c.WAL.Fsync != walFsyncAlways && c.WAL.Fsync != walFsyncInterval && c.WAL.Fsync != walFsyncNever {
+		invalid("wal.fsync", "must be %s, %s or %s, got %q", walFsyncAlways, walFsyncInterval, walFsyncNever, c.WAL.Fsync)
+	}
+	if c.WAL.Dir != "" && c.Snapshot.File == "" && c.WAL.CheckpointInterval <= 0 {
+		invalid("wal.checkpointInterval", "must be positive, got %s", c.WAL.CheckpointInterval)
+	}
+	if c.Shutdown.DrainDelay < 0 {
+		invalid("shutdown.drainDelay", "must not be negative, got %s", c.Shutdown.DrainDelay)
+	}
+	if c.Forward.Endpoint != "" {
+		if c.Forward.Protocol != otlpProtocolGRPC && c.Forward.Protocol != otlpProtocolHTTP {
+			invalid("forward.protocol", "must be %s or %s, got %q", otlpProtocolGRPC, otlpProtocolHTTP, c.Forward.Protocol)
+		}
+		if c.Forward.Timeout <= 0 {
+			invalid("forward.timeout", "must be positive, got %s", c.Forward.Timeout)
+		}
+		if c.Forward.QueueSize <= 0 {
+			invalid("forward.queueSize", "must be positive, got %d", c.Forward.QueueSize)
+		}
+		if c.Forward.BatchSize <= 0 {
+			invalid("forward.batchSize", "must be positive, got %d", c.Forward.BatchSize)
+		}
+		if c.Forward.BatchTimeout <= 0 {
+			invalid("forward.batchTimeout", "must be positive, got %s", c.Forward.BatchTimeout)
+		}
+		if c.Forward.Retry.InitialInterval <= 0 {
+			invalid("forward.retry.initialInterval", "must be positive, got %s", c.Forward.Retry.InitialInterval)
+		}
+		if c.Forward.Retry.MaxInterval < c.Forward.Retry.InitialInterval {
+			invalid("forward.retry.maxInterval", "must not be less than the initial interval %s, got %s", c.Forward.Retry.InitialInterval, c.Forward.Retry.MaxInterval)
+		}
+		if c.Forward.Sampling.TargetPerWindow < 0 {
+			invalid("forward.sampling.targetPerWindow", "must not be negative, got %d", c.Forward.Sampling.TargetPerWindow)
+		}
+		if c.Forward.Retry.MaxElapsedTime < 0 {
+			invalid("forward.retry.maxElapsedTime", "must not be negative, got %s", c.Forward.Retry.MaxElapsedTime)
+		}
+	}
+	if c.watchInterval < 0 {
+		invalid("configWatchInterval", "must not be negative, got %s", c.watchInterval)
+	}
+	if _, err := newBodyPattern(c.Attribute.BodyPattern); err != nil {
+		invalid("attribute.bodyPattern", "%v", err)
+	}
+	if _, err := newValueRenderer(c.Attribute.Rendering, c.Attribute.Typed); err != nil {
+		invalid("attribute.rendering", "%v", err)
+	}
+	if _, err := parseSeverityRange(c.Severity.Min); err != nil {
+		invalid("severity.min", "%v", err)
+	}
+	if _, err := newValueFilter(c.Filter.Include, c.Filter.Exclude, nil); err != nil {
+		errs = append(errs, fmt.Errorf("%w (set by config file %s)", err, c.path))
+	}
+	if _, err := newValueTransforms(c.Transforms); err != nil {
+		errs = append(errs, fmt.Errorf("%w (set by config file %s)", err, c.path))
+	}
+	if c.Values.StaleAfter < 0 {
+		invalid("values.staleAfter", "must not be negative, got %s", c.Values.StaleAfter)
+	}
+	if c.Anomaly.Deviations < 0 {
+		invalid("anomaly.deviations", "must not be negative, got %g", c.Anomaly.Deviations)
+	}
+	if c.Anomaly.Alpha <= 0 || c.Anomaly.Alpha > 1 {
+		invalid("anomaly.alpha", "must be greater than 0 and at most 1, got %g", c.Anomaly.Alpha)
+	}
+	if c.Anomaly.WarmupWindows < 1 {
+		invalid("anomaly.warmupWindows", "must be positive, got %d", c.Anomaly.WarmupWindows)
+	}
+	if c.Anomaly.SeasonWindows < 0 {
+		invalid("anomaly.seasonWindows", "must not be negative, got %d", c.Anomaly.SeasonWindows)
+	}
+	if c.Alerts.Webhook.URL != "" {
+		if u, err := url.Parse(c.Alerts.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("alerts.webhook.url", "must be an http or https URL, got %q", c.Alerts.Webhook.URL)
+		}
+		if c.Alerts.Webhook.Timeout <= 0 {
+			invalid("alerts.webhook.timeout", "must be positive, got %s", c.Alerts.Webhook.Timeout)
+		}
+	}
+	if _, err := newAlertEvaluator(c.Alerts.Rules); err != nil {
+		errs = append(errs, fmt.Errorf("%w (set by config file %s)", err, c.path))
+	}
+	return errors.Join(errs...)
+}
+
+// writeTo prints the effective configuration as YAML, annotated with the source of every setting.
+func (c *config) writeTo(w io.Writer) error {
+	var document yaml.Node
+	if err := document.Encode(c); err != nil {
+		return err
+	}
+	annotateSources(&document, "", c.sources)
+
+	fmt.Fprintf(w, "# config file: %s (set by %s)\n", cmp.Or(c.path, "none"), c.sources["config"])
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func annotateSources(node *yaml.Node, path string, sources map[string]string) {
+	if node.Kind != yaml.MappingNode {
+		for _, child := range node.Content {
+			annotateSources(child, path, sources)
+		}
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		setting := key.Value
+		if path != "" {
+			setting = path + "." + key.Value
+		}
+		if value.Kind == yaml.ScalarNode {
+			if flagName, ok := settingFlags[setting]; ok {
+				value.LineComment = fmt.Sprintf("%s, -%s, %s", sources[flagName], flagName, envVar(flagName))
+			}
+			continue
+		}
+		annotateSources(value, setting, sources)
+	}
+}
+
+// restartRequired returns the settings which differ between c and next but can only be applied by a restart.
+func (c *config) restartRequired(next *config) (settings []string) {
+	if c.Listen != next.Listen {
+		settings = append(settings, "listen")
+	}
+	if c.Admin != next.Admin {
+		settings = append(settings, "admin")
+	}
+	if c.Intake.BufferSize != next.Intake.BufferSize {
+		settings = append(settings, "intake.bufferSize")
+	}
+	if c.Snapshot.File != next.Snapshot.File {
+		settings = append(settings, "snapshot.file")
+	}
+	if c.WAL.Dir != next.WAL.Dir || c.WAL.Fsync != next.WAL.Fsync {
+		settings = append(settings, "wal")
+	}
+	if c.Forward != next.Forward {
+		settings = append(settings, "forward")
+	}
+	return
+}
+
+// keepRestartRequired copies the settings which can only be applied by a restart from current.
+func (c *config) keepRestartRequired(current *config) {
+	c.Listen = current.Listen
+	c.Admin = current.Admin
+	c.Intake.BufferSize = current.Intake.BufferSize
+	c.Snapshot.File = current.Snapshot.File
+	c.WAL.Dir = current.WAL.Dir
+	c.WAL.Fsync = current.WAL.Fsync
+	c.Forward = current.Forward
+}
+
+// configReloader reloads the configuration on SIGHUP or when the config file changes.
+// Settings which can be changed while running are applied without dropping the in-memory stats,
+// changed listener and storage settings are reported and kept until the next restart.
+type configReloader struct {
+	args    []string
+	current *config
+	apply   func(*config) error
+	modTime time.Time
+}
+
+func newConfigReloader(args []string, current *config, apply func(*config) error) *configReloader {
+	r := &configReloader{
+		args:    args,
+		current: current,
+		apply:   apply,
+	}
+	r.modTime, _ = r.configModTime()
+	return r
+}
+
+// run reloads the configuration until ctx is done.
+func (r *configReloader) run(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	var watch <-chan time.Time
+	if r.current.path != "" && r.current.watchInterval > 0 {
+		ticker := time.NewTicker(r.current.watchInterval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			slog.Info("Received SIGHUP, reloading configuration")
+			r.reload()
+		case <-watch:
+			modTime, err := r.configModTime()
+			if err != nil || modTime.Equal(r.modTime) {
+				continue
+			}
+			r.modTime = modTime
+			slog.Info("Config file changed, reloading configuration", slog.String("path", r.current.path))
+			r.reload()
+		}
+	}
+}
+
+func (r *configReloader) reload() {
+	next, err := loadConfig(r.args, flag.ContinueOnError)
+	if err != nil {
+		slog.Error("Failed to reload configuration, keeping the current configuration", slog.Any("error", err))
+		return
+	}
+	if settings := r.current.restartRequired(next); len(settings) > 0 {
+		slog.Warn("Changed settings require a restart and are ignored", slog.Any("settings", settings))
+		next.keepRestartRequired(r.current)
+	}
+	if reflect.DeepEqual(r.current, next) {
+		return
+	}
+
+	if err := r.apply(next); err != nil {
+		slog.Error("Failed to apply configuration, keeping the current configuration", slog.Any("error", err))
+		return
+	}
+	r.current = next
+}
+
+func (r *configReloader) configModTime() (time.Time, error) {
+	if r.current.path == "" {
+		return time.Time{}, nil
+	}
+	info, err := os.Stat(r.current.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig_Precedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+attribute:
+  key: deployment.environment
+window:
+  duration: 1m
+rateLimit:
+  recordsPerSecond: 500
+`)
+
+	tests := map[string]struct {
+		args             []string
+		expectedKey      string
+		expectedDuration time.Duration
+		expectedRate     float64
+	}{
+		"Defaults": {
+			args:             nil,
+			expectedKey:      "service.name",
+			expectedDuration: 10 * time.Second,
+			expectedRate:     0,
+		},
+		"ConfigFile": {
+			args:             []string{"-config", path},
+			expectedKey:      "deployment.environment",
+			expectedDuration: time.Minute,
+			expectedRate:     500,
+		},
+		"FlagOverridesConfigFile": {
+			args:             []string{"-attributeKey", "host.name", "-config", path},
+			expectedKey:      "host.name",
+			expectedDuration: time.Minute,
+			expectedRate:     500,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg, err := loadConfig(test.args, flag.ContinueOnError)
+			if err != nil {
+				t.Fatalf("loadConfig failed: %v", err)
+			}
+			if cfg.Attribute.Key != test.expectedKey {
+				t.Errorf("Expected attribute key '%s', got '%s'", test.expectedKey, cfg.Attribute.Key)
+			}
+			if cfg.Window.Duration != test.expectedDuration {
+				t.Errorf("Expected duration %s, got %s", test.expectedDuration, cfg.Window.Duration)
+			}
+			if cfg.RateLimit.RecordsPerSecond != test.expectedRate {
+				t.Errorf("Expected rate limit %g, got %g", test.expectedRate, cfg.RateLimit.RecordsPerSecond)
+			}
+			if cfg.Listen.Addr != "localhost:4317" {
+				t.Errorf("Expected default listen address, got '%s'", cfg.Listen.Addr)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_JSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"attribute": {"key": "k8s.namespace.name"}, "intake": {"bufferSize": 50}}`)
+
+	cfg, err := loadConfig([]string{"-config", path}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if cfg.Attribute.Key != "k8s.namespace.name" || cfg.Intake.BufferSize != 50 {
+		t.Errorf("Expected settings of the JSON file, got %+v", cfg)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		content       string
+		args          []string
+		expectedError string
+	}{
+		"UnknownKey": {
+			content:       "window:\n  durtion: 1m\n",
+			expectedError: "field durtion not found",
+		},
+		"NegativeDuration": {
+			content:       "window:\n  duration: -1s\n",
+			expectedError: "window.duration must be positive",
+		},
+		"InvalidFsync": {
+			content:       "wal:\n  fsync: sometimes\n",
+			expectedError: "wal.fsync must be",
+		},
+		"InvalidFilterRegex": {
+			content:       "filter:\n  exclude:\n    - regex: ['(']\n",
+			expectedError: "filter.exclude[0].regex",
+		},
+		"UnknownMinSeverity": {
+			content:       "severity:\n  min: warning\n",
+			expectedError: "severity.min unknown severity \"warning\"",
+		},
+		"BodyPatternWithoutCaptureGroup": {
+			content:       "attribute:\n  key: body\n  bodyPattern: 'status=\\d+'\n",
+			expectedError: "attribute.bodyPattern pattern",
+		},
+		"UnknownKeyMatch": {
+			content:       "attribute:\n  keyMatch: prefix\n",
+			expectedError: "attribute.keyMatch must be exact, glob or regex, got \"prefix\"",
+		},
+		"InvalidKeyRegex": {
+			content:       "",
+			args:          []string{"-attributeKeyMatch", "regex", "-attributeKey", "service_(name"},
+			expectedError: "attribute.key error parsing regexp",
+		},
+		"UnknownForwardProtocol": {
+			content:       "forward:\n  endpoint: collector:4317\n  protocol: http/json\n",
+			expectedError: "forward.protocol must be grpc or http/protobuf, got \"http/json\"",
+		},
+		"InvalidTransform": {
+			content:       "transforms:\n  - key: user.email\n",
+			expectedError: "transforms[0]: key user.email must have exactly one of hash, truncate, mask or cidr, got 0",
+		},
+		"AlertRuleWithTwoConditions": {
+			content:       "alerts:\n  rules:\n    - name: errors\n      above: 10\n      absentWindows: 2\n",
+			expectedError: "alerts.rules[0]: rule \"errors\" must have exactly one of above, below, changePercent or absentWindows, got 2",
+		},
+		"InvalidAlertWebhookURL": {
+			content:       "",
+			args:          []string{"-alertWebhookURL", "hooks.example.com/alerts"},
+			expectedError: "alerts.webhook.url must be an http or https URL",
+		},
+		"EmptyKeyFromFlag": {
+			content:       "",
+			args:          []string{"-attributeKey", ""},
+			expectedError: "attribute.key must not be empty",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path := writeConfigFile(t, "config.yaml", test.content)
+			_, err := loadConfig(append(test.args, "-config", path), flag.ContinueOnError)
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("Expected error containing '%s', got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_Environment(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+attribute:
+  key: deployment.environment
+window:
+  duration: 1m
+`)
+
+	tests := map[string]struct {
+		env              map[string]string
+		args             []string
+		expectedKey      string
+		expectedDuration time.Duration
+		expectedSource   string
+	}{
+		"EnvOverridesDefault": {
+			env:              map[string]string{"OTLP_PROCESSOR_ATTRIBUTE_KEY": "host.name"},
+			expectedKey:      "host.name",
+			expectedDuration: 10 * time.Second,
+			expectedSource:   "env OTLP_PROCESSOR_ATTRIBUTE_KEY",
+		},
+		"EnvOverridesConfigFile": {
+			env:              map[string]string{"OTLP_PROCESSOR_ATTRIBUTE_KEY": "host.name"},
+			args:             []string{"-config", path},
+			expectedKey:      "host.name",
+			expectedDuration: time.Minute,
+			expectedSource:   "env OTLP_PROCESSOR_ATTRIBUTE_KEY",
+		},
+		"FlagOverridesEnv": {
+			env:              map[string]string{"OTLP_PROCESSOR_ATTRIBUTE_KEY": "host.name"},
+			args:             []string{"-attributeKey", "k8s.pod.name", "-config", path},
+			expectedKey:      "k8s.pod.name",
+			expectedDuration: time.Minute,
+			expectedSource:   "flag -attributeKey",
+		},
+		"ConfigFileFromEnv": {
+			env:              map[string]string{"OTLP_PROCESSOR_CONFIG": path},
+			expectedKey:      "deployment.environment",
+			expectedDuration: time.Minute,
+			expectedSource:   "config file " + path,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := loadConfig(test.args, flag.ContinueOnError)
+			if err != nil {
+				t.Fatalf("loadConfig failed: %v", err)
+			}
+			if cfg.Attribute.Key != test.expectedKey {
+				t.Errorf("Expected attribute key '%s', got '%s'", test.expectedKey, cfg.Attribute.Key)
+			}
+			if cfg.Window.Duration != test.expectedDuration {
+				t.Errorf("Expected duration %s, got %s", test.expectedDuration, cfg.Window.Duration)
+			}
+			if cfg.sources["attributeKey"] != test.expectedSource {
+				t.Errorf("Expected source '%s', got '%s'", test.expectedSource, cfg.sources["attributeKey"])
+			}
+		})
+	}
+}
+
+func TestLoadConfig_InvalidNamesSource(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "window:\n  duration: -1s\n")
+
+	tests := map[string]struct {
+		env           map[string]string
+		args          []string
+		expectedError string
+	}{
+		"ConfigFile": {
+			args:          []string{"-config", path},
+			expectedError: "window.duration must be positive, got -1s (set by config file " + path + ")",
+		},
+		"Env": {
+			env:           map[string]string{"OTLP_PROCESSOR_DURATION": "-1m"},
+			expectedError: "window.duration must be positive, got -1m0s (set by env OTLP_PROCESSOR_DURATION)",
+		},
+		"Flag": {
+			env:           map[string]string{"OTLP_PROCESSOR_DURATION": "1m"},
+			args:          []string{"-duration", "0s"},
+			expectedError: "window.duration must be positive, got 0s (set by flag -duration)",
+		},
+		"UnparsableEnv": {
+			env:           map[string]string{"OTLP_PROCESSOR_BUFFER_SIZE": "many"},
+			expectedError: "env OTLP_PROCESSOR_BUFFER_SIZE: invalid value \"many\"",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			_, err := loadConfig(test.args, flag.ContinueOnError)
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("Expected error containing '%s', got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestEnvVar(t *testing.T) {
+	tests := map[string]string{
+		"attributeKey":          "OTLP_PROCESSOR_ATTRIBUTE_KEY",
+		"maxReceiveMessageSize": "OTLP_PROCESSOR_MAX_RECEIVE_MESSAGE_SIZE",
+		"duration":              "OTLP_PROCESSOR_DURATION",
+		"print-config":          "OTLP_PROCESSOR_PRINT_CONFIG",
+		"alertWebhookURL":       "OTLP_PROCESSOR_ALERT_WEBHOOK_URL",
+		"webhookURLTimeout":     "OTLP_PROCESSOR_WEBHOOK_URL_TIMEOUT",
+		"walCheckpointInterval": "OTLP_PROCESSOR_WAL_CHECKPOINT_INTERVAL",
+	}
+
+	for flagName, expected := range tests {
+		t.Run(flagName, func(t *testing.T) {
+			if got := envVar(flagName); got != expected {
+				t.Errorf("Expected '%s', got '%s'", expected, got)
+			}
+		})
+	}
+}
+
+func TestConfig_WriteTo(t *testing.T) {
+	t.Setenv("OTLP_PROCESSOR_DURATION", "1m")
+	cfg, err := loadConfig([]string{"-attributeKey", "host.name", "-print-config"}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if !cfg.printConfig {
+		t.Errorf("Expected print-config to be set")
+	}
+
+	var out strings.Builder
+	if err := cfg.writeTo(&out); err != nil {
+		t.Fatalf("writeTo failed: %v", err)
+	}
+	for _, expected := range []string{
+		"key: host.name # flag -attributeKey",
+		"duration: 1m0s # env OTLP_PROCESSOR_DURATION",
+		"bufferSize: 1000 # default, -bufferSize, OTLP_PROCESSOR_BUFFER_SIZE",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain '%s', got:\n%s", expected, out.String())
+		}
+	}
+
+	printed := writeConfigFile(t, "printed.yaml", out.String())
+	reloaded, err := loadConfig([]string{"-config", printed}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig of the printed config failed: %v", err)
+	}
+	if reloaded.Attribute.Key != "host.name" || reloaded.Window.Duration != time.Minute {
+		t.Errorf("Expected the printed config to load back, got %+v", reloaded)
+	}
+}
+
+func TestConfig_WriteTo_RedactsSalt(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "transforms:\n  - key: user.email\n    hash:\n      salt: 3f1c9a\n")
+	cfg, err := loadConfig([]string{"-config", path}, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	var out strings.Builder
+	if err := cfg.writeTo(&out); err != nil {
+		t.Fatalf("writeTo failed: %v", err)
+	}
+	if strings.Contains(out.String(), "3f1c9a") || !strings.Contains(out.String(), "salt: <redacted>") {
+		t.Errorf("Expected the salt to be redacted, got:\n%s", out.String())
+	}
+	if cfg.Transforms[0].Hash.Salt != "3f1c9a" {
+		t.Errorf("Expected the salt to be kept in the configuration, got '%s'", cfg.Transforms[0].Hash.Salt)
+	}
+}
+
+func TestConfig_RestartRequired(t *testing.T) {
+	current := defaultConfig()
+	next := defaultConfig()
+	next.Listen.Addr = "0.0.0.0:4317"
+	next.WAL.Dir = "/var/lib/wal"
+	next.Attribute.Key = "host.name"
+	next.Alerts.Rules = []alertRule{{Name: "loud", Above: uint64Pointer(10)}}
+
+	settings := current.restartRequired(next)
+	if strings.Join(settings, ",") != "listen,wal" {
+		t.Errorf("Expected listen and wal to require a restart, got %v", settings)
+	}
+
+	next.keepRestartRequired(current)
+	if next.Listen.Addr != current.Listen.Addr || next.WAL.Dir != current.WAL.Dir {
+		t.Errorf("Expected restart settings to be kept, got %+v", next)
+	}
+	if next.Attribute.Key != "host.name" || len(next.Alerts.Rules) != 1 {
+		t.Errorf("Expected live settings to be changed, got %+v", next)
+	}
+}
+
+func TestConfigReloader_Reload(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "attribute:\n  key: service.name\n")
+	args := []string{"-config", path}
+	cfg, err := loadConfig(args, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	var applied []*config
+	reloader := newConfigReloader(args, cfg, func(next *config) error {
+		applied = append(applied, next)
+		return nil
+	})
+
+	reloader.reload()
+	if len(applied) != 0 {
+		t.Errorf("Expected an unchanged configuration not to be applied, got %d", len(applied))
+	}
+
+	if err := os.WriteFile(path, []byte("attribute:\n  key: host.name\nlisten:\n  addr: 0.0.0.0:4317\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	reloader.reload()
+	if len(applied) != 1 || applied[0].Attribute.Key != "host.name" {
+		t.Fatalf("Expected the changed attribute key to be applied, got %v", applied)
+	}
+	if applied[0].Listen.Addr != "localhost:4317" {
+		t.Errorf("Expected the listen address to be kept until restart, got '%s'", applied[0].Listen.Addr)
+	}
+
+	if err := os.WriteFile(path, []byte("window:\n  duration: nonsense\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	reloader.reload()
+	if len(applied) != 1 || reloader.current.Attribute.Key != "host.name" {
+		t.Errorf("Expected an invalid configuration to be ignored, got %+v", reloader.current)
+	}
+}
+
+func TestConfigReloader_ReloadAliasFile(t *testing.T) {
+	aliasFile := writeConfigFile(t, "aliases.yaml", "service.name: [service_name]\n")
+	args := []string{"-aliasFile", aliasFile}
+	cfg, err := loadConfig(args, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	var applied []*config
+	reloader := newConfigReloader(args, cfg, func(next *config) error {
+		applied = append(applied, next)
+		return nil
+	})
+
+	if err := os.WriteFile(aliasFile, []byte("service.name: [serviceName]\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	reloader.reload()
+	if len(applied) != 1 || applied[0].aliasTable["service.name"][0] != "serviceName" {
+		t.Fatalf("Expected the changed alias file to be applied, got %v", applied)
+	}
+
+	if err := os.Remove(aliasFile); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	reloader.reload()
+	if len(applied) != 1 {
+		t.Errorf("Expected an unreadable alias file to be ignored, got %d applied", len(applied))
+	}
+}
+
+func TestConfigReloader_ReloadApplyFails(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "attribute:\n  key: service.name\n")
+	args := []string{"-config", path}
+	cfg, err := loadConfig(args, flag.ContinueOnError)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	reloader := newConfigReloader(args, cfg, func(next *config) error {
+		return errors.New("apply failed")
+	})
+	if err := os.WriteFile(path, []byte("attribute:\n  key: host.name\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	reloader.reload()
+	if reloader.current != cfg {
+		t.Errorf("Expected the current configuration to be kept, got %+v", reloader.current)
+	}
+}
+
+func TestLogsServiceServer_ApplyConfig(t *testing.T) {
+	server := newServer("localhost:4317", "service.name", time.Hour, 10)
+
+	cfg := defaultConfig()
+	cfg.Attribute.Key = "host.name"
+	cfg.Window.Duration = time.Minute
+	cfg.RateLimit.RecordsPerSecond = 100
+
+	if err := server.applyConfig(context.Background(), cfg); err != nil {
+		t.Fatalf("applyConfig failed: %v", err)
+	}
+	if server.attributeKey != "host.name" {
+		t.Errorf("Expected attribute key 'host.name', got '%s'", server.attributeKey)
+	}
+	if server.rateLimiter == nil || server.rateLimiter.burst != defaultRateLimitBurst {
+		t.Errorf("Expected the rate limiter to be enabled, got %+v", server.rateLimiter)
+	}
+
+	// The settings are applied by the processing loop, so Stats returns after the update.
+	stats, err := server.processor.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if len(stats.Total) != 0 {
+		t.Errorf("Expected no counts, got %v", stats.Total)
+	}
+	if server.processor.durationWindow != time.Minute {
+		t.Errorf("Expected duration window of 1m, got %s", server.processor.durationWindow)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// Metric attributes of the sampling decisions.
+const (
+	valueAttribute            = "com.dash0.homeexercise.value"
+	samplingDecisionAttribute = "com.dash0.homeexercise.sampling.decision"
+	samplingKept              = "kept"
+	samplingDropped           = "dropped"
+)
+
+// samplingSettings configure the adaptive sampling of the forwarded log records.
+type samplingSettings struct {
+	// target is the number of log records per value and window which are forwarded, 0 disables sampling.
+	target int
+	// probabilityAttribute names the attribute recording the sampling probability of the kept log records.
+	probabilityAttribute string
+}
+
+// processorWindow describes the windows of the processor to the enricher.
+type processorWindow struct {
+	// closed is the number of windows the processor closed, it changes when a new window starts.
+	closed uint64
+	// lastCounts are the counts of the last closed window.
+	lastCounts map[string]uint64
+}
+
+// logEnricher annotates the forwarded log records with the running count of their values in the current window,
+// and samples the log records of values exceeding the target volume.
+type logEnricher struct {
+	countAttribute string
+	sampling       samplingSettings
+	random         func() float64
+
+	// The counts are kept next to the processor, which only counts the values once they leave the intake.
+	mu     sync.Mutex
+	window uint64
+	counts map[string]int64
+}
+
+// newLogEnricher returns an enricher adding the count as countAttribute and sampling by sampling,
+// or nil if both are disabled.
+func newLogEnricher(countAttribute string, sampling samplingSettings) *logEnricher {
+	if countAttribute == "" && sampling.target <= 0 {
+		return nil
+	}
+	return &logEnricher{
+		countAttribute: countAttribute,
+		sampling:       sampling,
+		random:         rand.Float64,
+		counts:         make(map[string]int64),
+	}
+}
+
+// add counts the values of a log record within window, the number of windows the processor closed,
+// and returns the running counts of the values.
+func (en *logEnricher) add(window uint64, values []string) map[string]int64 {
+	en.mu.Lock()
+	defer en.mu.Unlock()
+	if window != en.window {
+		en.window = window
+		en.counts = make(map[string]int64)
+	}
+
+	running := make(map[string]int64, len(values))
+	for _, value := range values {
+		en.counts[value]++
+	}
+	for _, value := range values {
+		running[value] = en.counts[value]
+	}
+	return running
+}
+
+// probability returns the sampling probability of a value: the target divided by the count of the value
+// in the last window, or in the current window so far if that is higher. Values below the target are kept.
+func (en *logEnricher) probability(running int64, last uint64) float64 {
+	volume := float64(max(running, int64(last)))
+	if volume <= float64(en.sampling.target) {
+		return 1
+	}
+	return float64(en.sampling.target) / volume
+}
+
+// enrich returns a copy of request with the count attribute added to every log record which has counted values,
+// and the log records dropped by the sampling removed. It returns nil if all log records were dropped.
+// The values are counted per log record, also if they are taken from the resource or scope, in the order of the records.
+// A record with several values, e.g. of an exploded array, gets an array of their counts,
+// and is kept with the highest probability of its values.
+func (en *logEnricher) enrich(ctx context.Context, extractor valueExtractor, request *collogspb.ExportLogsServiceRequest, window processorWindow) *collogspb.ExportLogsServiceRequest {
+	extractor = extractor.withPath()
+	enriched := proto.Clone(request).(*collogspb.ExportLogsServiceRequest)
+
+	resourceLogs := enriched.ResourceLogs[:0]
+	for _, resourceLog := range enriched.GetResourceLogs() {
+		resourceAttributes := extractor.aliases.normalize(resourceLog.GetResource().GetAttributes())
+		scopeLogs := resourceLog.ScopeLogs[:0]
+		for _, scopeLog := range resourceLog.GetScopeLogs() {
+			scopeAttributes := extractor.aliases.normalize(scopeLog.GetScope().GetAttributes())
+			logRecords := scopeLog.LogRecords[:0]
+			for _, logRecord := range scopeLog.GetLogRecords() {
+				attributes := recordAttributes{
+					resourceAttributes, scopeAttributes, extractor.aliases.normalize(logRecord.Attributes),
+				}
+				matches, _ := extractor.recordValues(attributes, logRecord)
+				counted := extractor.countedValues(attributes, logRecord, matches, func(string) {})
+				if len(counted) > 0 && !en.annotate(ctx, logRecord, counted, en.add(window.closed, counted), window.lastCounts) {
+					continue
+				}
+				logRecords = append(logRecords, logRecord)
+			}
+			if len(logRecords) > 0 {
+				scopeLog.LogRecords = logRecords
+				scopeLogs = append(scopeLogs, scopeLog)
+			}
+		}
+		if len(scopeLogs) > 0 {
+			resourceLog.ScopeLogs = scopeLogs
+			resourceLogs = append(resourceLogs, resourceLog)
+		}
+	}
+	if len(resourceLogs) == 0 {
+		return nil
+	}
+	enriched.ResourceLogs = resourceLogs
+	return enriched
+}
+
+// annotate adds the attributes to a log record with counted values and reports whether the sampling keeps it.
+func (en *logEnricher) annotate(ctx context.Context, logRecord *otellogs.LogRecord, counted []string, running map[string]int64, lastCounts map[string]uint64) bool {
+	if en.countAttribute != "" {
+		var count *otelcommon.AnyValue
+		if len(counted) == 1 {
+			count = intValue(running[counted[0]])
+		} else {
+			counts := make([]*otelcommon.AnyValue, len(counted))
+			for i, value := range counted {
+				counts[i] = intValue(running[value])
+			}
+			count = &otelcommon.AnyValue{Value: &otelcommon.AnyValue_ArrayValue{ArrayValue: &otelcommon.ArrayValue{Values: counts}}}
+		}
+		logRecord.Attributes = append(logRecord.Attributes, &otelcommon.KeyValue{Key: en.countAttribute, Value: count})
+	}
+
+	if en.sampling.target <= 0 {
+		return true
+	}
+	probability := 0.0
+	for _, value := range counted {
+		probability = max(probability, en.probability(running[value], lastCounts[value]))
+	}
+	keep := probability >= 1 || en.random() < probability
+	decision := samplingKept
+	if !keep {
+		decision = samplingDropped
+	}
+	for _, value := range counted {
+		sampledCounter.Add(ctx, 1, metric.WithAttributes(
+			attribute.String(valueAttribute, value), attribute.String(samplingDecisionAttribute, decision),
+		))
+	}
+	if keep && en.sampling.probabilityAttribute != "" {
+		logRecord.Attributes = append(logRecord.Attributes, &otelcommon.KeyValue{
+			Key:   en.sampling.probabilityAttribute,
+			Value: &otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: probability}},
+		})
+	}
+	return keep
+}
+
+func intValue(i int64) *otelcommon.AnyValue {
+	return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: i}}
+}
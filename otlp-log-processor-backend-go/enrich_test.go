@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otelresource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestLogEnricher_Add(t *testing.T) {
+	enricher := newLogEnricher("value.count", samplingSettings{})
+
+	if running := enricher.add(0, []string{"checkout", "cart", "checkout"}); running["checkout"] != 2 || running["cart"] != 1 {
+		t.Errorf("Expected checkout 2 and cart 1, got %v", running)
+	}
+	if running := enricher.add(0, []string{"checkout"}); running["checkout"] != 3 {
+		t.Errorf("Expected checkout 3, got %v", running)
+	}
+	if running := enricher.add(1, []string{"checkout"}); running["checkout"] != 1 {
+		t.Errorf("Expected checkout 1 in the next window, got %v", running)
+	}
+}
+
+func TestNewLogEnricher_Disabled(t *testing.T) {
+	if enricher := newLogEnricher("", samplingSettings{}); enricher != nil {
+		t.Errorf("Expected no enricher without count attribute, got %v", enricher)
+	}
+}
+
+// recordCounts returns the count attribute of every log record of request, -1 if it is missing.
+func recordCounts(request *collogspb.ExportLogsServiceRequest, countAttribute string) []int64 {
+	var counts []int64
+	for _, resourceLog := range request.GetResourceLogs() {
+		for _, scopeLog := range resourceLog.GetScopeLogs() {
+			for _, logRecord := range scopeLog.GetLogRecords() {
+				count := int64(-1)
+				for _, kv := range logRecord.GetAttributes() {
+					if kv.GetKey() == countAttribute {
+						count = kv.GetValue().GetIntValue()
+					}
+				}
+				counts = append(counts, count)
+			}
+		}
+	}
+	return counts
+}
+
+func TestLogEnricher_Enrich(t *testing.T) {
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			Resource: &otelresource.Resource{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "checkout")}},
+			ScopeLogs: []*otellogs.ScopeLogs{{
+				LogRecords: []*otellogs.LogRecord{
+					{},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "cart")}},
+					{},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "cart")}},
+				},
+			}},
+		}},
+	}
+
+	tests := map[string]struct {
+		extractor valueExtractor
+		expected  []int64
+	}{
+		"PerLevel": {
+			extractor: valueExtractor{attributeKey: "service.name"},
+			expected:  []int64{1, 1, 2, 2},
+		},
+		"PerRecord": {
+			extractor: valueExtractor{attributeKey: "service.name", severity: severitySettings{breakdown: true}},
+			expected:  []int64{1, 1, 2, 2},
+		},
+		"Filtered": {
+			extractor: valueExtractor{
+				attributeKey: "service.name",
+				filter:       &valueFilter{exclude: []compiledFilterRule{{exact: []string{"cart"}}}},
+			},
+			expected: []int64{1, -1, 2, -1},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			enricher := newLogEnricher("value.count", samplingSettings{})
+			enriched := enricher.enrich(context.Background(), test.extractor, request, processorWindow{})
+
+			counts := recordCounts(enriched, "value.count")
+			if len(counts) != len(test.expected) {
+				t.Fatalf("Expected %d log records, got %d", len(test.expected), len(counts))
+			}
+			for i := range counts {
+				if counts[i] != test.expected[i] {
+					t.Errorf("Expected counts %v, got %v", test.expected, counts)
+					break
+				}
+			}
+			for _, count := range recordCounts(request, "value.count") {
+				if count != -1 {
+					t.Errorf("Expected the original request to stay unchanged, got counts %v", recordCounts(request, "value.count"))
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestLogEnricher_Enrich_ExplodedArray(t *testing.T) {
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			ScopeLogs: []*otellogs.ScopeLogs{{
+				LogRecords: []*otellogs.LogRecord{{
+					Attributes: []*otelcommon.KeyValue{{Key: "tags", Value: arrayValue(stringBody("a"), stringBody("b"), stringBody("a"))}},
+				}},
+			}},
+		}},
+	}
+
+	extractor := valueExtractor{attributeKey: "tags", explodeArrays: true}
+	enriched := newLogEnricher("value.count", samplingSettings{}).enrich(context.Background(), extractor, request, processorWindow{})
+
+	attributes := enriched.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Attributes
+	counts := attributes[len(attributes)-1].GetValue().GetArrayValue().GetValues()
+	if len(counts) != 3 || counts[0].GetIntValue() != 2 || counts[1].GetIntValue() != 1 || counts[2].GetIntValue() != 2 {
+		t.Errorf("Expected counts [2 1 2], got %v", counts)
+	}
+}
+
+func TestLogsServiceServer_Export_ForwardEnriched(t *testing.T) {
+	service := newFakeLogsService()
+	cfg := testForwardConfig()
+	cfg.BatchSize = 1
+	forwarder := newLogsForwarder(startFakeLogsService(t, service), cfg)
+	defer func() { _ = forwarder.shutdown(context.Background()) }()
+
+	server := &dash0LogsServiceServer{
+		addr:         "localhost:4317",
+		attributeKey: "service.name",
+		logExport:    make(chan string, 10),
+		forwarder:    forwarder,
+		enricher:     newLogEnricher("value.count", samplingSettings{}),
+	}
+	if _, err := server.Export(context.Background(), createLogRecordAttributesRequest()); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	waitReceived(t, service)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if counts := recordCounts(service.requests[0], "value.count"); len(counts) != 1 || counts[0] != 1 {
+		t.Errorf("Expected the forwarded log record to carry count 1, got %v", counts)
+	}
+}
+
+func TestLogEnricher_Probability(t *testing.T) {
+	tests := map[string]struct {
+		running  int64
+		last     uint64
+		expected float64
+	}{
+		"BelowTarget": {
+			running:  5,
+			expected: 1,
+		},
+		"CurrentWindowAboveTarget": {
+			running:  20,
+			expected: 0.5,
+		},
+		"LastWindowAboveTarget": {
+			running:  5,
+			last:     40,
+			expected: 0.25,
+		},
+	}
+
+	enricher := newLogEnricher("", samplingSettings{target: 10})
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if got := enricher.probability(test.running, test.last); got != test.expected {
+				t.Errorf("Expected %g, got %g", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestLogEnricher_Enrich_Sampling(t *testing.T) {
+	logRecords := []*otellogs.LogRecord{
+		{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "quiet")}},
+	}
+	for range 4 {
+		logRecords = append(logRecords, &otellogs.LogRecord{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "loud")}})
+	}
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{ScopeLogs: []*otellogs.ScopeLogs{{LogRecords: logRecords}}}},
+	}
+
+	tests := map[string]struct {
+		random              float64
+		expectedRecords     int
+		expectedProbability float64
+		expectedDropped     int64
+	}{
+		"LoudDropped": {
+			random:              0.9,
+			expectedRecords:     1,
+			expectedProbability: 1,
+			expectedDropped:     4,
+		},
+		"LoudKept": {
+			random:              0.1,
+			expectedRecords:     5,
+			expectedProbability: 0.5,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reader := metric.NewManualReader()
+			counter, err := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test").Int64Counter("com.dash0.homeexercise.logs.sampled")
+			if err != nil {
+				t.Fatalf("Failed to create counter: %v", err)
+			}
+			originalCounter := sampledCounter
+			sampledCounter = counter
+			defer func() { sampledCounter = originalCounter }()
+
+			enricher := newLogEnricher("", samplingSettings{target: 2, probabilityAttribute: "sampling.probability"})
+			enricher.random = func() float64 { return test.random }
+			extractor := valueExtractor{attributeKey: "service.name"}
+			window := processorWindow{lastCounts: map[string]uint64{"loud": 4}}
+			enriched := enricher.enrich(context.Background(), extractor, request, window)
+
+			records := enriched.ResourceLogs[0].ScopeLogs[0].LogRecords
+			if len(records) != test.expectedRecords {
+				t.Fatalf("Expected %d log records, got %d", test.expectedRecords, len(records))
+			}
+			last := records[len(records)-1].Attributes
+			if probability := last[len(last)-1]; probability.Key != "sampling.probability" || probability.Value.GetDoubleValue() != test.expectedProbability {
+				t.Errorf("Expected sampling.probability %g, got %v", test.expectedProbability, probability)
+			}
+
+			var resourceMetrics metricdata.ResourceMetrics
+			if err := reader.Collect(context.Background(), &resourceMetrics); err != nil {
+				t.Fatalf("Failed to collect metrics: %v", err)
+			}
+			decisions := make(map[string]int64)
+			for _, sm := range resourceMetrics.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+						value, _ := dp.Attributes.Value(valueAttribute)
+						decision, _ := dp.Attributes.Value(samplingDecisionAttribute)
+						decisions[value.AsString()+" "+decision.AsString()] += dp.Value
+					}
+				}
+			}
+			if decisions["quiet kept"] != 1 || decisions["loud dropped"] != test.expectedDropped || decisions["loud kept"] != 4-test.expectedDropped {
+				t.Errorf("Expected quiet kept once and loud dropped %d times, got %v", test.expectedDropped, decisions)
+			}
+		})
+	}
+}
+
+func TestLogEnricher_Enrich_SamplingResourceValue(t *testing.T) {
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			Resource:  &otelresource.Resource{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "checkout")}},
+			ScopeLogs: []*otellogs.ScopeLogs{{LogRecords: []*otellogs.LogRecord{{}, {}, {}}}},
+		}},
+	}
+
+	enricher := newLogEnricher("value.count", samplingSettings{target: 1})
+	enricher.random = func() float64 { return 0.9 }
+	enriched := enricher.enrich(context.Background(), valueExtractor{attributeKey: "service.name"}, request, processorWindow{})
+
+	// Every record counts towards the target, although the value is taken from their resource once.
+	counts := recordCounts(enriched, "value.count")
+	if len(counts) != 1 || counts[0] != 1 {
+		t.Errorf("Expected only the first log record to be kept with count 1, got counts %v", counts)
+	}
+}
+
+func TestLogEnricher_Enrich_AllDropped(t *testing.T) {
+	request := createLogRecordAttributesRequest()
+	enricher := newLogEnricher("", samplingSettings{target: 1})
+	enricher.random = func() float64 { return 0.99 }
+	extractor := valueExtractor{attributeKey: "service.name"}
+	values, _ := extractor.extract(context.Background(), request)
+
+	window := processorWindow{lastCounts: map[string]uint64{}}
+	for _, value := range values {
+		window.lastCounts[value] = 100
+	}
+	if enriched := enricher.enrich(context.Background(), extractor, request, window); enriched != nil {
+		t.Errorf("Expected nil when all log records are dropped, got %v", enriched)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"regexp"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// attributeHits counts the matches of the attribute key per level.
+type attributeHits struct {
+	resource  int
+	scope     int
+	logRecord int
+	// filtered counts the matches whose value was rejected by the filter.
+	filtered int
+	// filteredLogRecords counts the log records with a rejected value, a rejected resource or scope value
+	// stands for all log records of the resource or scope.
+	filteredLogRecords int
+}
+
+// valueExtractor holds the settings of an Export which decide what is counted.
+type valueExtractor struct {
+	attributeKey string
+	// keyPattern matches the keys of the attributes to count instead of the attributeKey path if set.
+	keyPattern *regexp.Regexp
+	// countKey counts the values of keyPattern as matched_key=value.
+	countKey bool
+	// aliases normalizes the attribute keys before they are matched.
+	aliases       keyAliases
+	bodyPattern   *regexp.Regexp
+	explodeArrays bool
+	renderer      valueRenderer
+	filter        *valueFilter
+	transforms    valueTransforms
+	severity      severitySettings
+
+	// path is the parsed attributeKey, it is parsed by extract if not set.
+	path []pathSegment
+}
+
+// extract returns the values to count for the request.
+func (e valueExtractor) extract(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (values []string, hits attributeHits) {
+	e = e.withPath()
+	if _, ok := bodyPath(e.path); ok || e.severity.enabled() {
+		return e.extractPerRecord(ctx, request)
+	}
+	return e.extractPerLevel(ctx, request)
+}
+
+// withPath returns e with the attributeKey parsed into path, unless the key is a pattern.
+// An attribute key renamed by the aliases is looked up by its canonical key, as the attributes are normalized.
+func (e valueExtractor) withPath() valueExtractor {
+	if e.path == nil && e.keyPattern == nil {
+		e.path = attributePath(e.attributeKey)
+		if _, isBody := bodyPath(e.path); !isBody {
+			e.path = e.aliases.canonicalPath(e.path)
+		}
+	}
+	return e
+}
+
+// reject counts a value which is not counted, rejectedBy names the reason.
+func (e valueExtractor) reject(ctx context.Context, hits *attributeHits, rejectedBy string) {
+	filteredValuesCounter.Add(ctx, 1, metric.WithAttributes(attribute.String(filterAttribute, rejectedBy)))
+	hits.filtered++
+}
+
+// rejectLogRecords counts the log records with a rejected value.
+func (e valueExtractor) rejectLogRecords(ctx context.Context, hits *attributeHits, logRecords int) {
+	if logRecords == 0 {
+		return
+	}
+	filteredLogRecordsCounter.Add(ctx, int64(logRecords))
+	hits.filteredLogRecords += logRecords
+}
+
+// extractPerLevel returns the string values of the attribute key found in the resource, scope and log record attributes.
+// Every occurrence is counted once, i.e. a resource attribute once for all the log records of the resource.
+// Values rejected by the filter are counted as filtered instead.
+func (e valueExtractor) extractPerLevel(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (values []string, hits attributeHits) {
+	// add counts the values which pass the filter and reports whether one was rejected.
+	add := func(matches []renderedValue, attributes recordAttributes) (rejected bool) {
+		for _, value := range matches {
+			if ok, rejectedBy := e.filter.allow(value.text, attributes, e.renderer); !ok {
+				e.reject(ctx, &hits, rejectedBy)
+				rejected = true
+				continue
+			}
+			values = append(values, e.label(value))
+		}
+		return rejected
+	}
+
+	if request.ResourceLogs != nil {
+		for _, resourceLog := range request.ResourceLogs {
+			resourceRejected := false
+			resourceAttributes := e.aliases.normalize(resourceLog.GetResource().GetAttributes())
+			if resourceAttributes != nil {
+				if matches := e.attributeValues(resourceAttributes); matches != nil {
+					resourceAttributeHitCounter.Add(ctx, 1)
+					hits.resource++
+					resourceRejected = add(matches, recordAttributes{resourceAttributes})
+				}
+			}
+			if resourceLog.ScopeLogs != nil {
+				for _, scopeLog := range resourceLog.ScopeLogs {
+					rejectedLogRecords := 0
+					scopeAttributes := e.aliases.normalize(scopeLog.GetScope().GetAttributes())
+					if scopeLog.LogRecords != nil {
+						for _, logRecord := range scopeLog.LogRecords {
+							if logRecord.Attributes != nil {
+								logRecordAttributes := e.aliases.normalize(logRecord.Attributes)
+								if matches := e.attributeValues(logRecordAttributes); matches != nil {
+									logAttributeHitCounter.Add(ctx, 1)
+									hits.logRecord++
+									if add(matches, recordAttributes{resourceAttributes, scopeAttributes, logRecordAttributes}) {
+										rejectedLogRecords++
+									}
+								}
+							}
+						}
+					}
+					scopeRejected := false
+					if scopeAttributes != nil {
+						if matches := e.attributeValues(scopeAttributes); matches != nil {
+							scopeAttributeHitCounter.Add(ctx, 1)
+							hits.scope++
+							scopeRejected = add(matches, recordAttributes{resourceAttributes, scopeAttributes})
+						}
+					}
+					if resourceRejected || scopeRejected {
+						rejectedLogRecords = len(scopeLog.GetLogRecords())
+					}
+					e.rejectLogRecords(ctx, &hits, rejectedLogRecords)
+				}
+			}
+		}
+	}
+
+	return values, hits
+}
+
+// extractPerRecord returns the values of every log record carrying the attribute key, looked up in the log record,
+// scope and resource attributes, in that order, or within the body if the key addresses the body.
+// The severity of the record decides whether the value is counted and, with the severity breakdown, becomes part of it.
+func (e valueExtractor) extractPerRecord(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (values []string, hits attributeHits) {
+	for _, resourceLog := range request.GetResourceLogs() {
+		resourceAttributes := e.aliases.normalize(resourceLog.GetResource().GetAttributes())
+		for _, scopeLog := range resourceLog.GetScopeLogs() {
+			scopeAttributes := e.aliases.normalize(scopeLog.GetScope().GetAttributes())
+			for _, logRecord := range scopeLog.GetLogRecords() {
+				attributes := recordAttributes{
+					resourceAttributes, scopeAttributes, e.aliases.normalize(logRecord.Attributes),
+				}
+				matches, level := e.recordValues(attributes, logRecord)
+				if matches == nil {
+					continue
+				}
+				switch level {
+				case resourceLevel:
+					resourceAttributeHitCounter.Add(ctx, 1)
+					hits.resource++
+				case scopeLevel:
+					scopeAttributeHitCounter.Add(ctx, 1)
+					hits.scope++
+				default:
+					logAttributeHitCounter.Add(ctx, 1)
+					hits.logRecord++
+				}
+
+				rejected := false
+				values = append(values, e.countedValues(attributes, logRecord, matches, func(rejectedBy string) {
+					e.reject(ctx, &hits, rejectedBy)
+					rejected = true
+				})...)
+				if rejected {
+					e.rejectLogRecords(ctx, &hits, 1)
+				}
+			}
+		}
+	}
+	return values, hits
+}
+
+// countedValues returns the strings counted for the values of a log record which pass the minimum severity
+// and the filter, with the severity range if the severity breakdown is enabled.
+// reject is called with the kind of rule which rejected a value.
+func (e valueExtractor) countedValues(attributes recordAttributes, logRecord *otellogs.LogRecord, matches []renderedValue, reject func(rejectedBy string)) []string {
+	var values []string
+	severity := recordSeverity(logRecord)
+	for _, value := range matches {
+		if severity < e.severity.min {
+			reject(filterSeverity)
+			continue
+		}
+		if ok, rejectedBy := e.filter.allow(value.text, attributes, e.renderer); !ok {
+			reject(rejectedBy)
+			continue
+		}
+		labelled := e.label(value)
+		if e.severity.breakdown {
+			labelled = withSeverityRange(labelled, severity)
+		}
+		values = append(values, labelled)
+	}
+	return values
+}
+
+// attributeValues returns the values of the attribute key within attributes, or nil if it is not present.
+func (e valueExtractor) attributeValues(attributes []*otelcommon.KeyValue) []renderedValue {
+	if e.keyPattern != nil {
+		return e.matchingValues(attributes, nil)
+	}
+	value, ok := resolveKeyValues(attributes, e.path)
+	if !ok {
+		return nil
+	}
+	return e.render(value, nil, e.attributeKey)
+}
+
+// recordValues returns the values of the attribute key for a log record and the level it was found at.
+// The body belongs to the log record level.
+func (e valueExtractor) recordValues(attributes recordAttributes, logRecord *otellogs.LogRecord) (values []renderedValue, level int) {
+	if path, isBody := bodyPath(e.path); isBody {
+		// Unlike attributes, a missing or empty body has no value.
+		value, ok := resolveValue(logRecord.GetBody(), path)
+		if !ok || value.GetValue() == nil {
+			return nil, logRecordLevel
+		}
+		return e.render(value, e.bodyPattern, e.attributeKey), logRecordLevel
+	}
+
+	if e.keyPattern != nil {
+		// Inner levels shadow the keys of outer ones, the record is counted at the innermost level with a match.
+		seen := make(map[string]bool)
+		for i := len(attributes) - 1; i >= 0; i-- {
+			if matches := e.matchingValues(attributes[i], seen); matches != nil {
+				if values == nil {
+					level = i
+				}
+				values = append(values, matches...)
+			}
+		}
+		return values, level
+	}
+
+	value, level, ok := attributes.resolve(e.path)
+	if !ok {
+		return nil, level
+	}
+	return e.render(value, nil, e.attributeKey), level
+}
+
+// matchingValues returns the values of all attributes whose key matches the key pattern, or nil if there are none.
+// Keys in seen are skipped, matched keys are added to it if it is not nil.
+func (e valueExtractor) matchingValues(attributes []*otelcommon.KeyValue, seen map[string]bool) []renderedValue {
+	var values []renderedValue
+	for _, kv := range attributes {
+		if seen[kv.GetKey()] || !e.keyPattern.MatchString(kv.GetKey()) {
+			continue
+		}
+		if seen != nil {
+			seen[kv.GetKey()] = true
+		}
+		for _, value := range e.render(kv.GetValue(), nil, kv.GetKey()) {
+			if e.countKey {
+				value.key = kv.GetKey()
+			}
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// renderedValue is a value converted to a string by the renderer, before it is labelled with its type.
+type renderedValue struct {
+	text      string
+	valueType string
+	// key is the matched attribute key if it is counted along with the value.
+	key string
+	// source is the attribute key the value was found at, selecting its transforms.
+	source string
+}
+
+// label returns the string to count for value. Transformed values are strings.
+func (e valueExtractor) label(value renderedValue) string {
+	if text, ok := e.transforms.apply(value.source, value.text); ok {
+		value.text, value.valueType = text, "string"
+	}
+	labelled := e.renderer.label(value.text, value.valueType)
+	if value.key != "" {
+		return value.key + "=" + labelled
+	}
+	return labelled
+}
+
+// render converts a value into the strings to count: the elements of an array if arrays are exploded,
+// or the capture group of pattern, which is always a string. source is the attribute key of value.
+func (e valueExtractor) render(value *otelcommon.AnyValue, pattern *regexp.Regexp, source string) []renderedValue {
+	elements := []*otelcommon.AnyValue{value}
+	if e.explodeArrays {
+		elements = explodeValue(value)
+	}
+
+	values := make([]renderedValue, 0, len(elements))
+	for _, element := range elements {
+		if pattern == nil {
+			values = append(values, renderedValue{text: e.renderer.render(element), valueType: valueType(element), source: source})
+		} else if match, ok := matchBodyPattern(element, pattern); ok {
+			values = append(values, renderedValue{text: match, valueType: "string", source: source})
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+const (
+	filterInclude = "include"
+	filterExclude = "exclude"
+	// filterSeverity rejects the values of log records below the minimum severity.
+	filterSeverity = "severity"
+)
+
+// filterRule matches a value by exact comparison, prefix or regular expression.
+// Key names the attribute of the record whose value is tested, the counted attribute value if empty.
+// Like the attribute key, it may be a path into kvlist and array values.
+type filterRule struct {
+	Key    string   `yaml:"key,omitempty"`
+	Exact  []string `yaml:"exact,omitempty"`
+	Prefix []string `yaml:"prefix,omitempty"`
+	Regex  []string `yaml:"regex,omitempty"`
+}
+
+type compiledFilterRule struct {
+	path   []pathSegment
+	exact  []string
+	prefix []string
+	regex  []*regexp.Regexp
+}
+
+// valueFilter decides whether an extracted value is counted.
+// A value is counted if every include rule and no exclude rule matches.
+// A rule on another attribute does not match if the record does not carry that attribute.
+type valueFilter struct {
+	include []compiledFilterRule
+	exclude []compiledFilterRule
+}
+
+// newValueFilter compiles the rules. It returns nil if there are no rules, which counts every value.
+// Rule keys renamed by aliases are looked up by their canonical keys, as the attributes are normalized.
+func newValueFilter(include []filterRule, exclude []filterRule, aliases keyAliases) (*valueFilter, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	var errs []error
+	compile := func(kind string, rules []filterRule) []compiledFilterRule {
+		compiled := make([]compiledFilterRule, 0, len(rules))
+		for i, rule := range rules {
+			if len(rule.Exact) == 0 && len(rule.Prefix) == 0 && len(rule.Regex) == 0 {
+				errs = append(errs, fmt.Errorf("filter.%s[%d] must have exact, prefix or regex values", kind, i))
+				continue
+			}
+			c := compiledFilterRule{
+				exact:  rule.Exact,
+				prefix: rule.Prefix,
+			}
+			if rule.Key != "" {
+				path, err := parseAttributePath(rule.Key)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("filter.%s[%d].key: %w", kind, i, err))
+					continue
+				}
+				c.path = aliases.canonicalPath(path)
+			}
+			for _, expr := range rule.Regex {
+				re, err := regexp.Compile(expr)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("filter.%s[%d].regex: %w", kind, i, err))
+					continue
+				}
+				c.regex = append(c.regex, re)
+			}
+			compiled = append(compiled, c)
+		}
+		return compiled
+	}
+
+	f := &valueFilter{
+		include: compile(filterInclude, include),
+		exclude: compile(filterExclude, exclude),
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// allow reports whether value is counted. If not, it returns the kind of rule which rejected it.
+// The values of other attributes are rendered like the counted values by renderer, without their type.
+func (f *valueFilter) allow(value string, attributes recordAttributes, renderer valueRenderer) (ok bool, rejectedBy string) {
+	if f == nil {
+		return true, ""
+	}
+	for _, rule := range f.include {
+		if !rule.matches(value, attributes, renderer) {
+			return false, filterInclude
+		}
+	}
+	for _, rule := range f.exclude {
+		if rule.matches(value, attributes, renderer) {
+			return false, filterExclude
+		}
+	}
+	return true, ""
+}
+
+func (r compiledFilterRule) matches(value string, attributes recordAttributes, renderer valueRenderer) bool {
+	if r.path != nil {
+		resolved, _, ok := attributes.resolve(r.path)
+		if !ok {
+			return false
+		}
+		value = renderer.render(resolved)
+	}
+
+	if slices.Contains(r.exact, value) {
+		return true
+	}
+	for _, prefix := range r.prefix {
+		if strings.HasPrefix(value, prefix) {
+			return true
+		}
+	}
+	for _, re := range r.regex {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordAttributes are the attributes in scope of an extracted value, from the outermost to the innermost level:
+// the resource, the scope and the log record. Inner levels shadow outer ones.
+type recordAttributes [][]*otelcommon.KeyValue
+
+// The levels of recordAttributes.
+const (
+	resourceLevel = iota
+	scopeLevel
+	logRecordLevel
+)
+
+// resolve returns the value of the path and the level it was found at.
+func (a recordAttributes) resolve(path []pathSegment) (value *otelcommon.AnyValue, level int, ok bool) {
+	for i := len(a) - 1; i >= 0; i-- {
+		if value, ok := resolveKeyValues(a[i], path); ok {
+			return value, i, true
+		}
+	}
+	return nil, 0, false
+}
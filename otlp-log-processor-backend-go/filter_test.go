@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otelresource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func stringKeyValue(key string, value string) *otelcommon.KeyValue {
+	return &otelcommon.KeyValue{
+		Key:   key,
+		Value: &otelcommon.AnyValue{Value: &otelcommon.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func TestValueFilter_Allow(t *testing.T) {
+	attributes := recordAttributes{
+		{stringKeyValue("deployment.environment", "production"), stringKeyValue("http.route", "/api")},
+		{stringKeyValue("http.route", "/healthz")},
+	}
+
+	tests := map[string]struct {
+		include            []filterRule
+		exclude            []filterRule
+		value              string
+		expected           bool
+		expectedRejectedBy string
+	}{
+		"NoRules": {
+			value:    "checkout",
+			expected: true,
+		},
+		"IncludeExact": {
+			include:  []filterRule{{Exact: []string{"checkout", "payment"}}},
+			value:    "payment",
+			expected: true,
+		},
+		"IncludeExactMismatch": {
+			include:            []filterRule{{Exact: []string{"checkout"}}},
+			value:              "payment",
+			expected:           false,
+			expectedRejectedBy: filterInclude,
+		},
+		"IncludeOtherAttribute": {
+			include:  []filterRule{{Key: "deployment.environment", Exact: []string{"production"}}},
+			value:    "checkout",
+			expected: true,
+		},
+		"IncludeMissingAttribute": {
+			include:            []filterRule{{Key: "k8s.cluster.name", Regex: []string{".*"}}},
+			value:              "checkout",
+			expected:           false,
+			expectedRejectedBy: filterInclude,
+		},
+		"ExcludePrefix": {
+			exclude:            []filterRule{{Prefix: []string{"health"}}},
+			value:              "healthcheck",
+			expected:           false,
+			expectedRejectedBy: filterExclude,
+		},
+		"ExcludeInnerAttributeShadowsOuter": {
+			exclude:            []filterRule{{Key: "http.route", Exact: []string{"/healthz"}}},
+			value:              "checkout",
+			expected:           false,
+			expectedRejectedBy: filterExclude,
+		},
+		"ExcludeRegex": {
+			exclude:  []filterRule{{Regex: []string{`^kube-probe/\d+`}}},
+			value:    "checkout",
+			expected: true,
+		},
+		"IncludeAndExclude": {
+			include:            []filterRule{{Key: "deployment.environment", Exact: []string{"production"}}},
+			exclude:            []filterRule{{Regex: []string{"^test-"}}},
+			value:              "test-checkout",
+			expected:           false,
+			expectedRejectedBy: filterExclude,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			filter, err := newValueFilter(test.include, test.exclude, nil)
+			if err != nil {
+				t.Fatalf("newValueFilter failed: %v", err)
+			}
+			ok, rejectedBy := filter.allow(test.value, attributes, valueRenderer{})
+			if ok != test.expected {
+				t.Errorf("Expected allow %t, got %t", test.expected, ok)
+			}
+			if rejectedBy != test.expectedRejectedBy {
+				t.Errorf("Expected rejected by '%s', got '%s'", test.expectedRejectedBy, rejectedBy)
+			}
+		})
+	}
+}
+
+func TestNewValueFilter_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		include       []filterRule
+		exclude       []filterRule
+		expectedError string
+	}{
+		"InvalidRegex": {
+			exclude:       []filterRule{{Regex: []string{"("}}},
+			expectedError: "filter.exclude[0].regex",
+		},
+		"EmptyRule": {
+			include:       []filterRule{{Key: "service.name"}},
+			expectedError: "filter.include[0] must have exact, prefix or regex values",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := newValueFilter(test.include, test.exclude, nil)
+			if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+				t.Errorf("Expected error containing '%s', got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestExtractValues_Filter(t *testing.T) {
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{
+			{
+				Resource: &otelresource.Resource{
+					Attributes: []*otelcommon.KeyValue{stringKeyValue("deployment.environment", "production")},
+				},
+				ScopeLogs: []*otellogs.ScopeLogs{
+					{
+						LogRecords: []*otellogs.LogRecord{
+							{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "checkout")}},
+							{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "healthcheck")}},
+						},
+					},
+				},
+			},
+			{
+				Resource: &otelresource.Resource{
+					Attributes: []*otelcommon.KeyValue{stringKeyValue("deployment.environment", "staging")},
+				},
+				ScopeLogs: []*otellogs.ScopeLogs{
+					{
+						LogRecords: []*otellogs.LogRecord{
+							{Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "payment")}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	filter, err := newValueFilter(
+		[]filterRule{{Key: "deployment.environment", Exact: []string{"production"}}},
+		[]filterRule{{Prefix: []string{"health"}}},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("newValueFilter failed: %v", err)
+	}
+
+	values, hits := valueExtractor{attributeKey: "service.name", filter: filter}.extract(context.Background(), request)
+	if !slices.Equal(values, []string{"checkout"}) {
+		t.Errorf("Expected [checkout], got %v", values)
+	}
+	if hits.logRecord != 3 || hits.filtered != 2 || hits.filteredLogRecords != 2 {
+		t.Errorf("Expected 3 log record hits and 2 filtered values of 2 log records, got %+v", hits)
+	}
+}
+
+func TestExtractValues_FilteredLogRecords(t *testing.T) {
+	record := func(service string, severity otellogs.SeverityNumber) *otellogs.LogRecord {
+		return &otellogs.LogRecord{
+			SeverityNumber: severity,
+			Attributes:     []*otelcommon.KeyValue{stringKeyValue("service.name", service)},
+		}
+	}
+	info, debug := otellogs.SeverityNumber_SEVERITY_NUMBER_INFO, otellogs.SeverityNumber_SEVERITY_NUMBER_DEBUG
+
+	tests := map[string]struct {
+		attributeKey               string
+		resourceLogs               []*otellogs.ResourceLogs
+		minSeverity                severityRange
+		expectedFiltered           int
+		expectedFilteredLogRecords int
+	}{
+		"ResourceValue": {
+			attributeKey: "deployment.environment",
+			resourceLogs: []*otellogs.ResourceLogs{{
+				Resource: &otelresource.Resource{
+					Attributes: []*otelcommon.KeyValue{stringKeyValue("deployment.environment", "staging")},
+				},
+				ScopeLogs: []*otellogs.ScopeLogs{
+					{LogRecords: []*otellogs.LogRecord{record("checkout", info), record("cart", info)}},
+					{LogRecords: []*otellogs.LogRecord{record("payment", info)}},
+				},
+			}},
+			expectedFiltered:           1,
+			expectedFilteredLogRecords: 3,
+		},
+		"ScopeAndLogRecordValues": {
+			attributeKey: "service.name",
+			resourceLogs: []*otellogs.ResourceLogs{{
+				ScopeLogs: []*otellogs.ScopeLogs{{
+					Scope: &otelcommon.InstrumentationScope{
+						Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "healthcheck")},
+					},
+					LogRecords: []*otellogs.LogRecord{record("healthcheck", info), record("checkout", info)},
+				}},
+			}},
+			expectedFiltered:           2,
+			expectedFilteredLogRecords: 2,
+		},
+		"PerRecord": {
+			attributeKey: "service.name",
+			resourceLogs: []*otellogs.ResourceLogs{{
+				ScopeLogs: []*otellogs.ScopeLogs{{
+					LogRecords: []*otellogs.LogRecord{record("checkout", debug), record("healthcheck", info), record("cart", info)},
+				}},
+			}},
+			minSeverity:                severityInfo,
+			expectedFiltered:           2,
+			expectedFilteredLogRecords: 2,
+		},
+	}
+
+	filter, err := newValueFilter(nil, []filterRule{{Prefix: []string{"health", "staging"}}}, nil)
+	if err != nil {
+		t.Fatalf("newValueFilter failed: %v", err)
+	}
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			extractor := valueExtractor{
+				attributeKey: test.attributeKey,
+				filter:       filter,
+				severity:     severitySettings{min: test.minSeverity},
+			}
+			request := &collogspb.ExportLogsServiceRequest{ResourceLogs: test.resourceLogs}
+			_, hits := extractor.extract(context.Background(), request)
+			if hits.filtered != test.expectedFiltered || hits.filteredLogRecords != test.expectedFilteredLogRecords {
+				t.Errorf("Expected %d filtered values of %d log records, got %+v", test.expectedFiltered, test.expectedFilteredLogRecords, hits)
+			}
+		})
+	}
+}
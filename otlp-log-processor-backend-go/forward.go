@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// forwardReasonAttribute names why log records could not be forwarded.
+const forwardReasonAttribute = "com.dash0.homeexercise.forward.reason"
+
+// The reasons of forwardReasonAttribute.
+const (
+	// forwardQueueFull drops the request because the forward queue is full.
+	forwardQueueFull = "queue_full"
+	// forwardRejected counts the records rejected by the endpoint in a partial success.
+	forwardRejected = "rejected"
+	// forwardExportFailed drops the batch after a permanent error or when the retries are exhausted.
+	forwardExportFailed = "export_failed"
+)
+
+// forwardClient exports a request to the downstream OTLP endpoint.
+// It returns the number of log records the endpoint rejected in a partial success.
+type forwardClient interface {
+	export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (rejected int64, err error)
+	close() error
+}
+
+// permanentError marks an export error which is not retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// newForwardClient connects to the endpoint of cfg by its protocol.
+func newForwardClient(cfg forwardConfig) (forwardClient, error) {
+	if cfg.Protocol == otlpProtocolHTTP {
+		return newHTTPForwardClient(cfg.Endpoint, cfg.Insecure)
+	}
+	return newGRPCForwardClient(cfg.Endpoint, cfg.Insecure)
+}
+
+type grpcForwardClient struct {
+	conn   *grpc.ClientConn
+	client collogspb.LogsServiceClient
+}
+
+// newGRPCForwardClient creates a client of the LogsService at endpoint, a host:port pair.
+func newGRPCForwardClient(endpoint string, insecureTransport bool, opts ...grpc.DialOption) (*grpcForwardClient, error) {
+	creds := credentials.NewTLS(nil)
+	if insecureTransport {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(endpoint, append([]grpc.DialOption{grpc.WithTransportCredentials(creds)}, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to forward endpoint: %w", err)
+	}
+	return &grpcForwardClient{conn: conn, client: collogspb.NewLogsServiceClient(conn)}, nil
+}
+
+func (c *grpcForwardClient) export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (int64, error) {
+	response, err := c.client.Export(ctx, request)
+	if err != nil {
+		// The codes the OTLP specification considers retryable.
+		switch status.Code(err) {
+		case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange, codes.Unavailable,
+			codes.DataLoss, codes.ResourceExhausted:
+			return 0, err
+		default:
+			return 0, &permanentError{err}
+		}
+	}
+	return response.GetPartialSuccess().GetRejectedLogRecords(), nil
+}
+
+func (c *grpcForwardClient) close() error {
+	return c.conn.Close()
+}
+
+type httpForwardClient struct {
+	url    string
+	client *http.Client
+}
+
+// newHTTPForwardClient creates a client posting protobuf requests to endpoint.
+// An endpoint without scheme uses http if insecureTransport is set, https otherwise,
+// and an endpoint without path the default path /v1/logs.
+func newHTTPForwardClient(endpoint string, insecureTransport bool) (*httpForwardClient, error) {
+	scheme := "https"
+	if insecureTransport {
+		scheme = "http"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		u, err = url.Parse(scheme + "://" + endpoint)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing forward endpoint: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/logs"
+	}
+	return &httpForwardClient{url: u.String(), client: &http.Client{}}, nil
+}
+
+func (c *httpForwardClient) export(ctx context.Context, request *collogspb.ExportLogsServiceRequest) (int64, error) {
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return 0, &permanentError{err}
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, &permanentError{err}
+	}
+	httpRequest.Header.Set("Content-Type", "application/x-protobuf")
+
+	response, err := c.client.Do(httpRequest)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		var exportResponse collogspb.ExportLogsServiceResponse
+		if err := proto.Unmarshal(data, &exportResponse); err != nil {
+			// The records were accepted, only the partial success is unknown.
+			return 0, nil
+		}
+		return exportResponse.GetPartialSuccess().GetRejectedLogRecords(), nil
+	case response.StatusCode == http.StatusTooManyRequests, response.StatusCode == http.StatusBadGateway,
+		response.StatusCode == http.StatusServiceUnavailable, response.StatusCode == http.StatusGatewayTimeout:
+		return 0, fmt.Errorf("forward endpoint responded %s", response.Status)
+	default:
+		return 0, &permanentError{fmt.Errorf("forward endpoint responded %s", response.Status)}
+	}
+}
+
+func (c *httpForwardClient) close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
+
+// logsForwarder re-exports the accepted requests to a downstream OTLP endpoint.
+// The requests are queued, merged into batches of at least batchSize log records or the requests
+// arriving within batchTimeout, and retried with exponential backoff.
+type logsForwarder struct {
+	client forwardClient
+	cfg    forwardConfig
+	queue  chan *collogspb.ExportLogsServiceRequest
+
+	// ctx is canceled if the shutdown does not finish in time, aborting the pending exports.
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// newLogsForwarder starts forwarding to client with the queue, batch and retry settings of cfg.
+func newLogsForwarder(client forwardClient, cfg forwardConfig) *logsForwarder {
+	ctx, cancel := context.WithCancel(context.Background())
+	f := &logsForwarder{
+		client: client,
+		cfg:    cfg,
+		queue:  make(chan *collogspb.ExportLogsServiceRequest, cfg.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go f.run()
+	return f
+}
+
+// enqueue queues request for forwarding. If the queue is full, the request is dropped and false returned.
+func (f *logsForwarder) enqueue(ctx context.Context, request *collogspb.ExportLogsServiceRequest) bool {
+	select {
+	case f.queue <- request:
+		return true
+	default:
+		forwardFailedCounter.Add(ctx, int64(countLogRecords(request)),
+			metric.WithAttributes(attribute.String(forwardReasonAttribute, forwardQueueFull)))
+		return false
+	}
+}
+
+// shutdown forwards the queued requests and closes the client. If ctx ends first, the pending exports are aborted.
+func (f *logsForwarder) shutdown(ctx context.Context) error {
+	close(f.stop)
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		f.cancel()
+		<-f.done
+	}
+	f.cancel()
+	return f.client.close()
+}
+
+func (f *logsForwarder) run() {
+	defer close(f.done)
+
+	var batch *collogspb.ExportLogsServiceRequest
+	records := 0
+	timer := time.NewTimer(f.cfg.BatchTimeout)
+	timer.Stop()
+
+	flush := func() {
+		if batch != nil {
+			f.send(batch, records)
+			batch, records = nil, 0
+		}
+	}
+	add := func(request *collogspb.ExportLogsServiceRequest) {
+		if batch == nil {
+			batch = &collogspb.ExportLogsServiceRequest{}
+			timer.Reset(f.cfg.BatchTimeout)
+		}
+		batch.ResourceLogs = append(batch.ResourceLogs, request.GetResourceLogs()...)
+		records += countLogRecords(request)
+		if records >= f.cfg.BatchSize {
+			timer.Stop()
+			flush()
+		}
+	}
+
+	for {
+		select {
+		case request := <-f.queue:
+			add(request)
+		case <-timer.C:
+			flush()
+		case <-f.stop:
+			timer.Stop()
+			for {
+				select {
+				case request := <-f.queue:
+					add(request)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send exports batch, retrying errors which are not permanent with exponential backoff
+// until the maximum elapsed time is reached.
+func (f *logsForwarder) send(batch *collogspb.ExportLogsServiceRequest, records int) {
+	start := time.Now()
+	interval := f.cfg.Retry.InitialInterval
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(f.ctx, f.cfg.Timeout)
+		rejected, err := f.client.export(ctx, batch)
+		cancel()
+		if err == nil {
+			forwardedCounter.Add(context.Background(), int64(records)-rejected)
+			if rejected > 0 {
+				forwardFailedCounter.Add(context.Background(), rejected,
+					metric.WithAttributes(attribute.String(forwardReasonAttribute, forwardRejected)))
+			}
+			return
+		}
+
+		// Jitter spreads the retries of several processors, waiting between half and one and a half intervals.
+		wait := interval/2 + rand.N(interval+1)
+		var permanent *permanentError
+		if errors.As(err, &permanent) || time.Since(start)+wait > f.cfg.Retry.MaxElapsedTime || f.ctx.Err() != nil {
+			slog.Warn("Failed to forward log records", slog.Int("records", records), slog.Int("attempts", attempt), slog.Any("error", err))
+			forwardFailedCounter.Add(context.Background(), int64(records),
+				metric.WithAttributes(attribute.String(forwardReasonAttribute, forwardExportFailed)))
+			return
+		}
+
+		slog.Debug("Retrying to forward log records", slog.Duration("wait", wait), slog.Any("error", err))
+		select {
+		case <-time.After(wait):
+		case <-f.ctx.Done():
+		}
+		interval = min(interval*2, f.cfg.Retry.MaxInterval)
+	}
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// fakeLogsService records the exported requests, failing the first failures calls with code.
+type fakeLogsService struct {
+	collogspb.UnimplementedLogsServiceServer
+
+	mu       sync.Mutex
+	calls    int
+	failures int
+	code     codes.Code
+	requests []*collogspb.ExportLogsServiceRequest
+	received chan struct{}
+}
+
+func newFakeLogsService() *fakeLogsService {
+	return &fakeLogsService{received: make(chan struct{}, 100)}
+}
+
+func (s *fakeLogsService) Export(_ context.Context, request *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return nil, status.Error(s.code, "fake failure")
+	}
+	s.requests = append(s.requests, request)
+	s.received <- struct{}{}
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+// startFakeLogsService serves service in-process and returns a client of it.
+func startFakeLogsService(t *testing.T, service *fakeLogsService) *grpcForwardClient {
+	t.Helper()
+	lis := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(server, service)
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	client, err := newGRPCForwardClient("passthrough:///bufnet", true, grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	if err != nil {
+		t.Fatalf("newGRPCForwardClient failed: %v", err)
+	}
+	return client
+}
+
+func testForwardConfig() forwardConfig {
+	cfg := defaultConfig().Forward
+	cfg.Retry.InitialInterval = time.Millisecond
+	cfg.Retry.MaxInterval = time.Millisecond * 10
+	cfg.Retry.MaxElapsedTime = time.Second * 5
+	return cfg
+}
+
+func forwardRequest(records int) *collogspb.ExportLogsServiceRequest {
+	logRecords := make([]*otellogs.LogRecord, records)
+	for i := range logRecords {
+		logRecords[i] = &otellogs.LogRecord{}
+	}
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{ScopeLogs: []*otellogs.ScopeLogs{{LogRecords: logRecords}}}},
+	}
+}
+
+// swapForwardCounters records the forward counters with a manual reader until the test ends.
+func swapForwardCounters(t *testing.T) *metric.ManualReader {
+	t.Helper()
+	reader := metric.NewManualReader()
+	testMeter := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test")
+	forwarded, err := testMeter.Int64Counter("com.dash0.homeexercise.logs.forwarded")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	failed, err := testMeter.Int64Counter("com.dash0.homeexercise.logs.forward.failed")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+
+	originalForwarded, originalFailed := forwardedCounter, forwardFailedCounter
+	forwardedCounter, forwardFailedCounter = forwarded, failed
+	t.Cleanup(func() { forwardedCounter, forwardFailedCounter = originalForwarded, originalFailed })
+	return reader
+}
+
+// collectForwardCounters returns the forwarded records and the failed records per reason.
+func collectForwardCounters(t *testing.T, reader *metric.ManualReader) (forwarded int64, failed map[string]int64) {
+	t.Helper()
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &resourceMetrics); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	failed = make(map[string]int64)
+	for _, sm := range resourceMetrics.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				if m.Name == "com.dash0.homeexercise.logs.forwarded" {
+					forwarded += dp.Value
+				} else {
+					reason, _ := dp.Attributes.Value(attribute.Key(forwardReasonAttribute))
+					failed[reason.AsString()] += dp.Value
+				}
+			}
+		}
+	}
+	return forwarded, failed
+}
+
+func waitReceived(t *testing.T, service *fakeLogsService) {
+	t.Helper()
+	select {
+	case <-service.received:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Timed out waiting for the forwarded request")
+	}
+}
+
+func TestLogsForwarder_Batching(t *testing.T) {
+	tests := map[string]struct {
+		batchSize       int
+		batchTimeout    time.Duration
+		requests        int
+		expectedBatches int
+	}{
+		"FullBatch": {
+			batchSize:       3,
+			batchTimeout:    time.Hour,
+			requests:        3,
+			expectedBatches: 1,
+		},
+		"BatchTimeout": {
+			batchSize:       100,
+			batchTimeout:    time.Millisecond * 10,
+			requests:        1,
+			expectedBatches: 1,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reader := swapForwardCounters(t)
+			service := newFakeLogsService()
+			cfg := testForwardConfig()
+			cfg.BatchSize, cfg.BatchTimeout = test.batchSize, test.batchTimeout
+			forwarder := newLogsForwarder(startFakeLogsService(t, service), cfg)
+
+			for range test.requests {
+				if !forwarder.enqueue(context.Background(), forwardRequest(1)) {
+					t.Fatalf("Expected the request to be queued")
+				}
+			}
+			// The batch is sent before the shutdown flushes it.
+			waitReceived(t, service)
+			if err := forwarder.shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown failed: %v", err)
+			}
+
+			service.mu.Lock()
+			batches, resourceLogs := len(service.requests), len(service.requests[0].ResourceLogs)
+			service.mu.Unlock()
+			if batches != test.expectedBatches || resourceLogs != test.requests {
+				t.Errorf("Expected %d batch of %d resource logs, got %d batches, the first of %d",
+					test.expectedBatches, test.requests, batches, resourceLogs)
+			}
+			if forwarded, _ := collectForwardCounters(t, reader); forwarded != int64(test.requests) {
+				t.Errorf("Expected %d forwarded records, got %d", test.requests, forwarded)
+			}
+		})
+	}
+}
+
+func TestLogsForwarder_Retry(t *testing.T) {
+	tests := map[string]struct {
+		failures          int
+		code              codes.Code
+		expectedCalls     int
+		expectedForwarded int64
+		expectedFailed    int64
+	}{
+		"RetryableRecovers": {
+			failures:          2,
+			code:              codes.Unavailable,
+			expectedCalls:     3,
+			expectedForwarded: 2,
+		},
+		"Permanent": {
+			failures:       1,
+			code:           codes.InvalidArgument,
+			expectedCalls:  1,
+			expectedFailed: 2,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reader := swapForwardCounters(t)
+			service := newFakeLogsService()
+			service.failures, service.code = test.failures, test.code
+			cfg := testForwardConfig()
+			cfg.BatchSize = 2
+			forwarder := newLogsForwarder(startFakeLogsService(t, service), cfg)
+
+			forwarder.enqueue(context.Background(), forwardRequest(2))
+			if err := forwarder.shutdown(context.Background()); err != nil {
+				t.Fatalf("shutdown failed: %v", err)
+			}
+
+			if service.calls != test.expectedCalls {
+				t.Errorf("Expected %d export calls, got %d", test.expectedCalls, service.calls)
+			}
+			forwarded, failed := collectForwardCounters(t, reader)
+			if forwarded != test.expectedForwarded || failed[forwardExportFailed] != test.expectedFailed {
+				t.Errorf("Expected %d forwarded and %d failed records, got %d and %d",
+					test.expectedForwarded, test.expectedFailed, forwarded, failed[forwardExportFailed])
+			}
+		})
+	}
+}
+
+func TestLogsForwarder_RetriesExhausted(t *testing.T) {
+	reader := swapForwardCounters(t)
+	service := newFakeLogsService()
+	service.failures, service.code = 1000, codes.Unavailable
+	cfg := testForwardConfig()
+	cfg.Retry.MaxElapsedTime = time.Millisecond * 20
+	forwarder := newLogsForwarder(startFakeLogsService(t, service), cfg)
+
+	forwarder.enqueue(context.Background(), forwardRequest(3))
+	if err := forwarder.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+
+	if service.calls < 2 {
+		t.Errorf("Expected the export to be retried, got %d calls", service.calls)
+	}
+	if _, failed := collectForwardCounters(t, reader); failed[forwardExportFailed] != 3 {
+		t.Errorf("Expected 3 failed records, got %d", failed[forwardExportFailed])
+	}
+}
+
+// blockingForwardClient blocks every export until release is closed.
+type blockingForwardClient struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *blockingForwardClient) export(ctx context.Context, _ *collogspb.ExportLogsServiceRequest) (int64, error) {
+	c.started <- struct{}{}
+	select {
+	case <-c.release:
+		return 0, nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+func (c *blockingForwardClient) close() error {
+	return nil
+}
+
+func TestLogsForwarder_QueueFull(t *testing.T) {
+	reader := swapForwardCounters(t)
+	client := &blockingForwardClient{started: make(chan struct{}, 10), release: make(chan struct{})}
+	cfg := testForwardConfig()
+	cfg.QueueSize, cfg.BatchSize = 1, 1
+	forwarder := newLogsForwarder(client, cfg)
+
+	forwarder.enqueue(context.Background(), forwardRequest(1))
+	<-client.started
+	if !forwarder.enqueue(context.Background(), forwardRequest(1)) {
+		t.Errorf("Expected the second request to be queued")
+	}
+	if forwarder.enqueue(context.Background(), forwardRequest(4)) {
+		t.Errorf("Expected the third request to be dropped")
+	}
+
+	close(client.release)
+	if err := forwarder.shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown failed: %v", err)
+	}
+	forwarded, failed := collectForwardCounters(t, reader)
+	if forwarded != 2 || failed[forwardQueueFull] != 4 {
+		t.Errorf("Expected 2 forwarded and 4 dropped records, got %d and %d", forwarded, failed[forwardQueueFull])
+	}
+}
+
+func TestLogsForwarder_ShutdownTimeout(t *testing.T) {
+	client := &blockingForwardClient{started: make(chan struct{}, 10), release: make(chan struct{})}
+	cfg := testForwardConfig()
+	cfg.BatchSize = 1
+	forwarder := newLogsForwarder(client, cfg)
+
+	forwarder.enqueue(context.Background(), forwardRequest(1))
+	<-client.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- forwarder.shutdown(ctx) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Expected shutdown to abort the blocked export")
+	}
+}
+
+func TestHTTPForwardClient(t *testing.T) {
+	tests := map[string]struct {
+		status            int
+		response          *collogspb.ExportLogsServiceResponse
+		expectedRejected  int64
+		expectedErr       bool
+		expectedPermanent bool
+	}{
+		"OK": {
+			status: http.StatusOK,
+		},
+		"PartialSuccess": {
+			status: http.StatusOK,
+			response: &collogspb.ExportLogsServiceResponse{
+				PartialSuccess: &collogspb.ExportLogsPartialSuccess{RejectedLogRecords: 1},
+			},
+			expectedRejected: 1,
+		},
+		"Unavailable": {
+			status:      http.StatusServiceUnavailable,
+			expectedErr: true,
+		},
+		"BadRequest": {
+			status:            http.StatusBadRequest,
+			expectedErr:       true,
+			expectedPermanent: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var received collogspb.ExportLogsServiceRequest
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+					t.Errorf("Expected a protobuf request to /v1/logs, got %s with %s", r.URL.Path, r.Header.Get("Content-Type"))
+				}
+				body, _ := io.ReadAll(r.Body)
+				if err := proto.Unmarshal(body, &received); err != nil {
+					t.Errorf("Failed to decode the request: %v", err)
+				}
+				w.WriteHeader(test.status)
+				if test.response != nil {
+					data, _ := proto.Marshal(test.response)
+					_, _ = w.Write(data)
+				}
+			}))
+			defer server.Close()
+
+			client, err := newHTTPForwardClient(server.URL, true)
+			if err != nil {
+				t.Fatalf("newHTTPForwardClient failed: %v", err)
+			}
+			rejected, err := client.export(context.Background(), forwardRequest(2))
+
+			var permanent *permanentError
+			if (err != nil) != test.expectedErr || errors.As(err, &permanent) != test.expectedPermanent {
+				t.Errorf("Expected error %t, permanent %t, got %v", test.expectedErr, test.expectedPermanent, err)
+			}
+			if rejected != test.expectedRejected {
+				t.Errorf("Expected %d rejected records, got %d", test.expectedRejected, rejected)
+			}
+			if countLogRecords(&received) != 2 {
+				t.Errorf("Expected 2 forwarded log records, got %d", countLogRecords(&received))
+			}
+		})
+	}
+}
+
+func TestNewHTTPForwardClient_URL(t *testing.T) {
+	tests := map[string]struct {
+		endpoint string
+		insecure bool
+		expected string
+	}{
+		"HostPort": {
+			endpoint: "collector:4318",
+			expected: "https://collector:4318/v1/logs",
+		},
+		"HostPortInsecure": {
+			endpoint: "collector:4318",
+			insecure: true,
+			expected: "http://collector:4318/v1/logs",
+		},
+		"CustomPath": {
+			endpoint: "https://collector/otlp/v1/logs",
+			expected: "https://collector/otlp/v1/logs",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			client, err := newHTTPForwardClient(test.endpoint, test.insecure)
+			if err != nil {
+				t.Fatalf("newHTTPForwardClient failed: %v", err)
+			}
+			if client.url != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, client.url)
+			}
+		})
+	}
+}
+
+func TestLogsServiceServer_Export_Forward(t *testing.T) {
+	service := newFakeLogsService()
+	cfg := testForwardConfig()
+	cfg.BatchSize = 1
+	forwarder := newLogsForwarder(startFakeLogsService(t, service), cfg)
+	defer func() { _ = forwarder.shutdown(context.Background()) }()
+
+	server := &dash0LogsServiceServer{
+		addr:         "localhost:4317",
+		attributeKey: "service.name",
+		logExport:    make(chan string, 10),
+		forwarder:    forwarder,
+	}
+	if _, err := server.Export(context.Background(), createLogRecordAttributesRequest()); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	waitReceived(t, service)
+
+	service.mu.Lock()
+	defer service.mu.Unlock()
+	if len(service.requests) != 1 || !proto.Equal(service.requests[0], createLogRecordAttributesRequest()) {
+		t.Errorf("Expected the request to be forwarded unchanged, got %v", service.requests)
+	}
+}
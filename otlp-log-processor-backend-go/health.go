@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthServices are the services whose serving status is reported, the empty name being the overall server status.
+var healthServices = []string{"", collogspb.LogsService_ServiceDesc.ServiceName}
+
+// minIntakeCheckInterval bounds the interval the intake is checked at for very short full durations.
+const minIntakeCheckInterval = time.Millisecond
+
+// intakeHealthMonitor reports NOT_SERVING while the log intake channel stays full for longer than fullDuration.
+// Load balancers can then move traffic to replicas which are able to keep up.
+type intakeHealthMonitor struct {
+	health       *health.Server
+	intake       func() (length int, capacity int)
+	fullDuration time.Duration
+	interval     time.Duration
+}
+
+func newIntakeHealthMonitor(healthServer *health.Server, server *dash0LogsServiceServer, fullDuration time.Duration) *intakeHealthMonitor {
+	return &intakeHealthMonitor{
+		health: healthServer,
+		intake: func() (int, int) {
+			return len(server.logExport), cap(server.logExport)
+		},
+		fullDuration: fullDuration,
+		interval:     min(max(fullDuration/5, minIntakeCheckInterval), time.Second),
+	}
+}
+
+// run checks the intake channel until ctx is done.
+// Once the health server is shut down for draining, the status updates are ignored by the health server.
+func (m *intakeHealthMonitor) run(ctx context.Context) {
+	setServingStatus(m.health, healthpb.HealthCheckResponse_SERVING)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	var fullSince time.Time
+	serving := true
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// An unbuffered intake, -bufferSize 0, holds no values to measure and is never considered full.
+			length, capacity := m.intake()
+			if capacity == 0 || length < capacity {
+				fullSince = time.Time{}
+				if !serving {
+					slog.Info("Log intake recovered, reporting SERVING")
+					setServingStatus(m.health, healthpb.HealthCheckResponse_SERVING)
+					serving = true
+				}
+				continue
+			}
+
+			if fullSince.IsZero() {
+				fullSince = now
+			}
+			if serving && now.Sub(fullSince) >= m.fullDuration {
+				slog.Warn("Log intake persistently full, reporting NOT_SERVING", slog.Duration("fullFor", now.Sub(fullSince)))
+				setServingStatus(m.health, healthpb.HealthCheckResponse_NOT_SERVING)
+				serving = false
+			}
+		}
+	}
+}
+
+// startIntakeHealthMonitor reports SERVING and starts monitoring the intake channel unless fullDuration is 0.
+// The returned function stops the monitor.
+func startIntakeHealthMonitor(ctx context.Context, healthServer *health.Server, server *dash0LogsServiceServer, fullDuration time.Duration) context.CancelFunc {
+	ctx, cancel := context.WithCancel(ctx)
+	if fullDuration > 0 {
+		go newIntakeHealthMonitor(healthServer, server, fullDuration).run(ctx)
+	} else {
+		setServingStatus(healthServer, healthpb.HealthCheckResponse_SERVING)
+	}
+	return cancel
+}
+
+func setServingStatus(healthServer *health.Server, status healthpb.HealthCheckResponse_ServingStatus) {
+	for _, service := range healthServices {
+		healthServer.SetServingStatus(service, status)
+	}
+}
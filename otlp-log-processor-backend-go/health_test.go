@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func servingStatus(t *testing.T, healthServer *health.Server, service string) healthpb.HealthCheckResponse_ServingStatus {
+	t.Helper()
+	response, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+	return response.GetStatus()
+}
+
+func waitForServingStatus(t *testing.T, healthServer *health.Server, expected healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if servingStatus(t, healthServer, "") == expected {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("Expected serving status %s, got %s", expected, servingStatus(t, healthServer, ""))
+}
+
+func TestNewIntakeHealthMonitor_Interval(t *testing.T) {
+	tests := map[string]struct {
+		fullDuration     time.Duration
+		expectedInterval time.Duration
+	}{
+		"Default": {
+			fullDuration:     5 * time.Second,
+			expectedInterval: time.Second,
+		},
+		"Short": {
+			fullDuration:     50 * time.Millisecond,
+			expectedInterval: 10 * time.Millisecond,
+		},
+		"Nanoseconds": {
+			fullDuration:     3 * time.Nanosecond,
+			expectedInterval: minIntakeCheckInterval,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			monitor := newIntakeHealthMonitor(health.NewServer(), &dash0LogsServiceServer{}, test.fullDuration)
+			if monitor.interval != test.expectedInterval {
+				t.Errorf("Expected interval %s, got %s", test.expectedInterval, monitor.interval)
+			}
+		})
+	}
+}
+
+func TestIntakeHealthMonitor_PersistentlyFull(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var length atomic.Int64
+	healthServer := health.NewServer()
+	monitor := &intakeHealthMonitor{
+		health:       healthServer,
+		intake:       func() (int, int) { return int(length.Load()), 10 },
+		fullDuration: 30 * time.Millisecond,
+		interval:     5 * time.Millisecond,
+	}
+	go monitor.run(ctx)
+
+	waitForServingStatus(t, healthServer, healthpb.HealthCheckResponse_SERVING)
+
+	length.Store(10)
+	waitForServingStatus(t, healthServer, healthpb.HealthCheckResponse_NOT_SERVING)
+	if status := servingStatus(t, healthServer, "opentelemetry.proto.collector.logs.v1.LogsService"); status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected LogsService to be NOT_SERVING, got %s", status)
+	}
+
+	length.Store(3)
+	waitForServingStatus(t, healthServer, healthpb.HealthCheckResponse_SERVING)
+}
+
+func TestIntakeHealthMonitor_Unbuffered(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	healthServer := health.NewServer()
+	monitor := &intakeHealthMonitor{
+		health:       healthServer,
+		intake:       func() (int, int) { return 0, 0 },
+		fullDuration: 10 * time.Millisecond,
+		interval:     2 * time.Millisecond,
+	}
+	go monitor.run(ctx)
+
+	waitForServingStatus(t, healthServer, healthpb.HealthCheckResponse_SERVING)
+	time.Sleep(50 * time.Millisecond)
+	if status := servingStatus(t, healthServer, ""); status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Expected an unbuffered intake to stay SERVING, got %s", status)
+	}
+}
+
+func TestIntakeHealthMonitor_Draining(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	healthServer := health.NewServer()
+	monitor := &intakeHealthMonitor{
+		health:       healthServer,
+		intake:       func() (int, int) { return 0, 10 },
+		fullDuration: 30 * time.Millisecond,
+		interval:     5 * time.Millisecond,
+	}
+	go monitor.run(ctx)
+	waitForServingStatus(t, healthServer, healthpb.HealthCheckResponse_SERVING)
+
+	healthServer.Shutdown()
+	time.Sleep(20 * time.Millisecond)
+
+	if status := servingStatus(t, healthServer, ""); status != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("Expected NOT_SERVING while draining, got %s", status)
+	}
+}
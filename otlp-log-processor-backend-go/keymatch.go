@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// keyMatchExact takes the attribute key as a path, see parseAttributePath.
+	keyMatchExact = "exact"
+	// keyMatchGlob takes the attribute key as a glob, where * matches any characters and ? a single one.
+	keyMatchGlob = "glob"
+	// keyMatchRegex takes the attribute key as a regular expression.
+	keyMatchRegex = "regex"
+)
+
+// newKeyPattern compiles the attribute key into a pattern matching whole attribute keys.
+// It returns nil for exact keys.
+func newKeyPattern(key string, match string) (*regexp.Regexp, error) {
+	switch match {
+	case "", keyMatchExact:
+		return nil, nil
+	case keyMatchGlob:
+		return regexp.Compile("^" + globExpr(key) + "$")
+	case keyMatchRegex:
+		return regexp.Compile("^(?:" + key + ")$")
+	default:
+		return nil, fmt.Errorf("unknown key match %q, expected %s, %s or %s", match, keyMatchExact, keyMatchGlob, keyMatchRegex)
+	}
+}
+
+// globExpr converts glob into a regular expression.
+func globExpr(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
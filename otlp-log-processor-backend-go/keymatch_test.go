@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otelresource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestNewKeyPattern(t *testing.T) {
+	tests := map[string]struct {
+		key        string
+		match      string
+		matches    []string
+		nonMatches []string
+	}{
+		"Glob": {
+			key:        "app.team.*",
+			match:      keyMatchGlob,
+			matches:    []string{"app.team.", "app.team.owner", "app.team.a.b"},
+			nonMatches: []string{"app.team", "app.teams.owner", "x.app.team.owner"},
+		},
+		"GlobSingleCharacter": {
+			key:        "service?name",
+			match:      keyMatchGlob,
+			matches:    []string{"service_name", "service.name"},
+			nonMatches: []string{"serviceName", "service__name"},
+		},
+		"Regex": {
+			key:        "service_name|serviceName",
+			match:      keyMatchRegex,
+			matches:    []string{"service_name", "serviceName"},
+			nonMatches: []string{"service.name", "my_service_name", "serviceNames"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			pattern, err := newKeyPattern(test.key, test.match)
+			if err != nil {
+				t.Fatalf("newKeyPattern failed: %v", err)
+			}
+			for _, key := range test.matches {
+				if !pattern.MatchString(key) {
+					t.Errorf("Expected '%s' to match '%s'", test.key, key)
+				}
+			}
+			for _, key := range test.nonMatches {
+				if pattern.MatchString(key) {
+					t.Errorf("Expected '%s' not to match '%s'", test.key, key)
+				}
+			}
+		})
+	}
+}
+
+func TestNewKeyPattern_Exact(t *testing.T) {
+	pattern, err := newKeyPattern("service.*", keyMatchExact)
+	if err != nil || pattern != nil {
+		t.Errorf("Expected no pattern and no error, got %v, %v", pattern, err)
+	}
+	if _, err := newKeyPattern("service.name", "prefix"); err == nil {
+		t.Errorf("Expected error for unknown key match, got nil")
+	}
+}
+
+func TestValueExtractor_Extract_KeyPattern(t *testing.T) {
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			Resource: &otelresource.Resource{Attributes: []*otelcommon.KeyValue{
+				stringKeyValue("service_name", "checkout"),
+				stringKeyValue("host.name", "node-1"),
+			}},
+			ScopeLogs: []*otellogs.ScopeLogs{{
+				LogRecords: []*otellogs.LogRecord{
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("serviceName", "cart")}},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("service_name", "payment")}},
+					{},
+				},
+			}},
+		}},
+	}
+
+	tests := map[string]struct {
+		countKey          bool
+		severity          severitySettings
+		expected          []string
+		expectedResource  int
+		expectedLogRecord int
+	}{
+		"PerLevel": {
+			expected:          []string{"cart", "checkout", "payment"},
+			expectedResource:  1,
+			expectedLogRecord: 2,
+		},
+		"PerLevelCountKey": {
+			countKey:          true,
+			expected:          []string{"serviceName=cart", "service_name=checkout", "service_name=payment"},
+			expectedResource:  1,
+			expectedLogRecord: 2,
+		},
+		"PerRecordShadowed": {
+			countKey: true,
+			severity: severitySettings{breakdown: true},
+			expected: []string{
+				"serviceName=cart [UNSPECIFIED]",
+				"service_name=checkout [UNSPECIFIED]",
+				"service_name=checkout [UNSPECIFIED]",
+				"service_name=payment [UNSPECIFIED]",
+			},
+			expectedResource:  1,
+			expectedLogRecord: 2,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			pattern, err := newKeyPattern("service_name|serviceName", keyMatchRegex)
+			if err != nil {
+				t.Fatalf("newKeyPattern failed: %v", err)
+			}
+			extractor := valueExtractor{keyPattern: pattern, countKey: test.countKey, severity: test.severity}
+			values, hits := extractor.extract(context.Background(), request)
+			slices.Sort(values)
+			if !slices.Equal(values, test.expected) {
+				t.Errorf("Expected values %v, got %v", test.expected, values)
+			}
+			if hits.resource != test.expectedResource || hits.logRecord != test.expectedLogRecord {
+				t.Errorf("Expected %d resource and %d log record hits, got %d and %d",
+					test.expectedResource, test.expectedLogRecord, hits.resource, hits.logRecord)
+			}
+		})
+	}
+}
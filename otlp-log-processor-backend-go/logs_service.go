@@ -4,29 +4,190 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type dash0LogsServiceServer struct {
-	addr         string
-	attributeKey string
-	processor    *dash0LogsProcessor
-	logExport    chan<- string
+	addr      string
+	processor *dash0LogsProcessor
+	logExport chan<- string
+
+	// mu guards the settings which can be changed by a config reload.
+	mu            sync.RWMutex
+	attributeKey  string
+	keyPattern    *regexp.Regexp
+	countKey      bool
+	aliases       keyAliases
+	rateLimiter   *clientRateLimiter
+	bodyPattern   *regexp.Regexp
+	explodeArrays bool
+	renderer      valueRenderer
+	filter        *valueFilter
+	transforms    valueTransforms
+	severity      severitySettings
+
+	wal   *writeAheadLog
+	walMu sync.Mutex
+
+	forwarder *logsForwarder
+	enricher  *logEnricher
 
 	collogspb.UnimplementedLogsServiceServer
 }
 
-func newServer(addr string, attributeKey string, durationWindow time.Duration, bufferSize uint) collogspb.LogsServiceServer {
+// serverOption configures optional behaviour of the dash0LogsServiceServer.
+type serverOption func(*dash0LogsServiceServer)
+
+// withRateLimit limits the log records per second each client may export.
+func withRateLimit(recordsPerSecond float64, burst int, tenantHeader string) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.rateLimiter = newClientRateLimiter(recordsPerSecond, burst, tenantHeader)
+	}
+}
+
+// withKeyPattern counts the values of every attribute whose key matches pattern, as matched_key=value if countKey is set.
+func withKeyPattern(pattern *regexp.Regexp, countKey bool) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.keyPattern = pattern
+		s.countKey = countKey
+	}
+}
+
+// withAliases normalizes the attribute keys by aliases before they are matched.
+func withAliases(aliases keyAliases) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.aliases = aliases
+	}
+}
+
+// withBodyPattern extracts the value from string bodies by the capture group of pattern.
+func withBodyPattern(pattern *regexp.Regexp) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.bodyPattern = pattern
+	}
+}
+
+// withExplodeArrays counts every element of array values separately.
+func withExplodeArrays() serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.explodeArrays = true
+	}
+}
+
+// withRenderer converts the values to strings with renderer.
+func withRenderer(renderer valueRenderer) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.renderer = renderer
+	}
+}
+
+// withForwarder forwards the accepted requests by forwarder.
+func withForwarder(forwarder *logsForwarder) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.forwarder = forwarder
+	}
+}
+
+// withEnricher annotates the forwarded log records by enricher.
+func withEnricher(enricher *logEnricher) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.enricher = enricher
+	}
+}
+
+// withTransforms transforms the values of their keys before they are counted.
+func withTransforms(transforms valueTransforms) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.transforms = transforms
+	}
+}
+
+// withFilter counts only the values accepted by filter.
+func withFilter(filter *valueFilter) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.filter = filter
+	}
+}
+
+// withSeverity counts the values per severity range if breakdown is set, and only of records at or above min.
+func withSeverity(breakdown bool, min severityRange) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.severity = severitySettings{breakdown: breakdown, min: min}
+	}
+}
+
+// withSnapshots writes the processor state to path every interval and on shutdown.
+// A previously written snapshot is restored before the processing starts.
+func withSnapshots(path string, interval time.Duration, restored *processorSnapshot) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.processor.snapshotPath = path
+		s.processor.snapshotInterval = interval
+		if restored != nil {
+			s.processor.restore(restored, time.Now())
+		}
+	}
+}
+
+// withStaleAfter reports values which were not counted for staleAfter as stale.
+func withStaleAfter(staleAfter time.Duration) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.processor.staleAfter = staleAfter
+	}
+}
+
+// withAnomalyDetection compares the counts of every closed window against the baselines of detector.
+func withAnomalyDetection(detector *anomalyDetector) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.processor.anomalies = detector
+	}
+}
+
+// withAlerts evaluates the alert rules of evaluator at every window close and passes their changes to notifier.
+// The evaluator may be nil, the rules can be added by a config reload.
+func withAlerts(evaluator *alertEvaluator, notifier *alertNotifier) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.processor.alerts = evaluator
+		s.processor.alertNotifier = notifier
+	}
+}
+
+// withWAL persists the extracted values in wal before Export acknowledges them.
+// The unprocessed tail of the log, starting at sequence from, is counted before the processing starts.
+func withWAL(wal *writeAheadLog, from uint64, unprocessed []string, checkpointInterval time.Duration) serverOption {
+	return func(s *dash0LogsServiceServer) {
+		s.wal = wal
+		s.processor.wal = wal
+		s.processor.walCheckpointInterval = checkpointInterval
+		s.processor.walSeq = from
+		for _, logValue := range unprocessed {
+			s.processor.count(logValue)
+		}
+	}
+}
+
+func newServer(addr string, attributeKey string, durationWindow time.Duration, bufferSize uint, opts ...serverOption) *dash0LogsServiceServer {
 	logIntakeChannel := make(chan string, bufferSize)
 
 	processor := &dash0LogsProcessor{
-		durationWindow: durationWindow,
-		logStats:       make(map[string]uint64),
-		logIntake:      logIntakeChannel,
+		durationWindow:  durationWindow,
+		logStats:        make(map[string]uint64),
+		windowStats:     make(map[string]uint64),
+		logIntake:       logIntakeChannel,
+		statsRequests:   make(chan chan processorStats),
+		settingsUpdates: make(chan processorSettings),
+		shutdown:        make(chan struct{}),
+		done:            make(chan struct{}),
 	}
 
 	s := &dash0LogsServiceServer{
@@ -35,6 +196,9 @@ func newServer(addr string, attributeKey string, durationWindow time.Duration, b
 		processor:    processor,
 		logExport:    logIntakeChannel,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
 
 	go processor.StartLogProcessing()
 
@@ -45,46 +209,193 @@ func (l *dash0LogsServiceServer) Export(ctx context.Context, request *collogspb.
 	slog.DebugContext(ctx, "Received ExportLogsServiceRequest")
 	logsReceivedCounter.Add(ctx, 1)
 
-	if request.ResourceLogs != nil {
-		for _, resourceLog := range request.ResourceLogs {
-			if resourceLog.Resource != nil && resourceLog.Resource.Attributes != nil {
-				for _, attributes := range resourceLog.Resource.Attributes {
-					if attributes.Key == l.attributeKey {
-						resourceAttributeHitCounter.Add(ctx, 1)
-						l.logExport <- extractStringValue(attributes.Value)
-					}
-				}
-			}
-			if resourceLog.ScopeLogs != nil {
-				for _, scopeLog := range resourceLog.ScopeLogs {
-					if scopeLog.LogRecords != nil {
-						for _, logRecord := range scopeLog.LogRecords {
-							if logRecord.Attributes != nil {
-								for _, logRecordAttribute := range logRecord.Attributes {
-									if logRecordAttribute.Key == l.attributeKey {
-										logAttributeHitCounter.Add(ctx, 1)
-										l.logExport <- extractStringValue(logRecordAttribute.Value)
-									}
-								}
-							}
-						}
-					}
-					if scopeLog.Scope != nil && scopeLog.Scope.Attributes != nil {
-						for _, scopeAttribute := range scopeLog.Scope.Attributes {
-							if scopeAttribute.Key == l.attributeKey {
-								scopeAttributeHitCounter.Add(ctx, 1)
-								l.logExport <- extractStringValue(scopeAttribute.Value)
-							}
-						}
-					}
+	l.mu.RLock()
+	extractor := valueExtractor{
+		attributeKey:  l.attributeKey,
+		keyPattern:    l.keyPattern,
+		countKey:      l.countKey,
+		aliases:       l.aliases,
+		bodyPattern:   l.bodyPattern,
+		explodeArrays: l.explodeArrays,
+		renderer:      l.renderer,
+		filter:        l.filter,
+		transforms:    l.transforms,
+		severity:      l.severity,
+	}
+	rateLimiter := l.rateLimiter
+	l.mu.RUnlock()
+
+	logRecords := countLogRecords(request)
+	logRecordsReceivedCounter.Add(ctx, int64(logRecords))
+	if rateLimiter != nil {
+		if err := rateLimiter.allow(ctx, logRecords); err != nil {
+			slog.DebugContext(ctx, "Rejected ExportLogsServiceRequest", slog.Any("error", err))
+			return nil, err
+		}
+	}
+
+	extractCtx, span := tracer.Start(ctx, "extract attribute values", trace.WithAttributes(
+		attribute.String(attributeKeyAttribute, extractor.attributeKey),
+		attribute.Int(resourceLogsAttribute, len(request.GetResourceLogs())),
+		attribute.Int(logRecordsAttribute, logRecords),
+	))
+	values, hits := extractor.extract(extractCtx, request)
+	span.SetAttributes(
+		attribute.Int(resourceMatchesAttribute, hits.resource),
+		attribute.Int(scopeMatchesAttribute, hits.scope),
+		attribute.Int(logRecordMatchesAttribute, hits.logRecord),
+		attribute.Int(filteredAttribute, hits.filtered),
+		attribute.Int(filteredLogRecordsAttribute, hits.filteredLogRecords),
+	)
+	span.End()
+
+	_, span = tracer.Start(ctx, "enqueue attribute values", trace.WithAttributes(
+		attribute.Int(valuesAttribute, len(values)),
+	))
+	start := time.Now()
+	err := l.enqueue(values)
+	wait := time.Since(start).Seconds()
+	enqueueDurationHistogram.Record(ctx, wait)
+	span.SetAttributes(attribute.Float64(enqueueWaitAttribute, wait))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(otelcodes.Error, "failed to persist log values")
+	}
+	// The span covers the enqueue only, not the forwarding below.
+	span.End()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to enqueue log values", slog.Any("error", err))
+		return nil, status.Error(codes.Unavailable, "failed to persist log values")
+	}
+
+	if l.forwarder != nil {
+		forwarded := request
+		if l.enricher != nil {
+			var window processorWindow
+			if l.processor != nil {
+				window.closed = l.processor.closedWindows.Load()
+				if lastCounts := l.processor.lastWindowCounts.Load(); lastCounts != nil {
+					window.lastCounts = *lastCounts
 				}
 			}
+			forwarded = l.enricher.enrich(ctx, extractor, request, window)
+		}
+		// A full forward queue does not fail the export, the values are already counted.
+		if forwarded != nil && !l.forwarder.enqueue(ctx, forwarded) {
+			slog.DebugContext(ctx, "Dropped ExportLogsServiceRequest from forwarding, the forward queue is full")
 		}
 	}
 
 	return &collogspb.ExportLogsServiceResponse{}, nil
 }
 
+// Span attributes describing the work of an Export.
+const (
+	attributeKeyAttribute     = "com.dash0.homeexercise.attribute.key"
+	resourceLogsAttribute     = "com.dash0.homeexercise.resource_logs"
+	logRecordsAttribute       = "com.dash0.homeexercise.log_records"
+	resourceMatchesAttribute  = "com.dash0.homeexercise.matches.resource"
+	scopeMatchesAttribute     = "com.dash0.homeexercise.matches.scope"
+	logRecordMatchesAttribute = "com.dash0.homeexercise.matches.log_record"
+	valuesAttribute           = "com.dash0.homeexercise.values"
+	enqueueWaitAttribute      = "com.dash0.homeexercise.enqueue.wait"
+	filteredAttribute         = "com.dash0.homeexercise.filtered"
+	filterAttribute           = "com.dash0.homeexercise.filter"
+
+	filteredLogRecordsAttribute = "com.dash0.homeexercise.filtered.log_records"
+)
+
+// enqueue hands the values to the processor.
+// With a write-ahead log the values are persisted first. The log and the intake channel must see the values in the
+// same order, as the processor derives the checkpointed sequence from the number of values it has counted.
+func (l *dash0LogsServiceServer) enqueue(values []string) error {
+	if l.wal != nil {
+		l.walMu.Lock()
+		defer l.walMu.Unlock()
+		if err := l.wal.append(values); err != nil {
+			return err
+		}
+	}
+
+	for _, value := range values {
+		l.logExport <- value
+	}
+	return nil
+}
+
+// applyConfig applies the settings which can be changed while the server is running.
+func (l *dash0LogsServiceServer) applyConfig(ctx context.Context, cfg *config) error {
+	aliases := newKeyAliases(cfg.Attribute.SemconvAliases, cfg.aliasTable)
+	filter, err := newValueFilter(cfg.Filter.Include, cfg.Filter.Exclude, aliases)
+	if err != nil {
+		return err
+	}
+	transforms, err := newValueTransforms(cfg.Transforms)
+	if err != nil {
+		return err
+	}
+	minSeverity, err := parseSeverityRange(cfg.Severity.Min)
+	if err != nil {
+		return err
+	}
+	keyPattern, err := newKeyPattern(cfg.Attribute.Key, cfg.Attribute.KeyMatch)
+	if err != nil {
+		return err
+	}
+	bodyPattern, err := newBodyPattern(cfg.Attribute.BodyPattern)
+	if err != nil {
+		return err
+	}
+	renderer, err := newValueRenderer(cfg.Attribute.Rendering, cfg.Attribute.Typed)
+	if err != nil {
+		return err
+	}
+	alerts, err := newAlertEvaluator(cfg.Alerts.Rules)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.attributeKey = cfg.Attribute.Key
+	l.keyPattern = keyPattern
+	l.countKey = cfg.Attribute.CountMatchedKey
+	l.aliases = aliases
+	l.bodyPattern = bodyPattern
+	l.explodeArrays = cfg.Attribute.ExplodeArrays
+	l.renderer = renderer
+	l.filter = filter
+	l.transforms = transforms
+	l.severity = severitySettings{breakdown: cfg.Severity.Breakdown, min: minSeverity}
+	switch {
+	case cfg.RateLimit.RecordsPerSecond <= 0:
+		l.rateLimiter = nil
+	case l.rateLimiter == nil:
+		l.rateLimiter = newClientRateLimiter(cfg.RateLimit.RecordsPerSecond, cfg.RateLimit.Burst, cfg.RateLimit.TenantHeader)
+	default:
+		l.rateLimiter.setLimits(cfg.RateLimit.RecordsPerSecond, cfg.RateLimit.Burst, cfg.RateLimit.TenantHeader)
+	}
+	l.mu.Unlock()
+
+	return l.processor.UpdateSettings(ctx, processorSettings{
+		durationWindow:        cfg.Window.Duration,
+		snapshotInterval:      cfg.Snapshot.Interval,
+		walCheckpointInterval: cfg.WAL.CheckpointInterval,
+		anomaly:               cfg.Anomaly.settings(),
+		staleAfter:            cfg.Values.StaleAfter,
+		alerts:                alerts,
+		alertWebhook:          cfg.Alerts.Webhook,
+	})
+}
+
+func countLogRecords(request *collogspb.ExportLogsServiceRequest) (logRecords int) {
+	for _, resourceLog := range request.GetResourceLogs() {
+		for _, scopeLog := range resourceLog.GetScopeLogs() {
+			logRecords += len(scopeLog.GetLogRecords())
+		}
+	}
+	return
+}
+
 func extractStringValue(value *otelcommon.AnyValue) (strValue string) {
 	switch v := value.GetValue().(type) {
 	case *otelcommon.AnyValue_StringValue:
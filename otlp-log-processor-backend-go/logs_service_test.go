@@ -5,8 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
 	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
@@ -377,6 +380,41 @@ func TestLogsServiceServer_Export_LogRecordAttributes(t *testing.T) {
 	}
 }
 
+func TestLogsServiceServer_Export_EmptyAttributeValue(t *testing.T) {
+	ctx := context.Background()
+
+	logExportChannel := make(chan string, 10)
+	server := &dash0LogsServiceServer{
+		addr:         "localhost:4317",
+		attributeKey: "service.name",
+		logExport:    logExportChannel,
+	}
+
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			ScopeLogs: []*otellogs.ScopeLogs{{
+				LogRecords: []*otellogs.LogRecord{{
+					Attributes: []*otelcommon.KeyValue{{Key: "service.name", Value: &otelcommon.AnyValue{}}},
+				}},
+			}},
+		}},
+	}
+
+	_, err := server.Export(ctx, request)
+	if err != nil {
+		t.Errorf("Export failed: %v", err)
+	}
+
+	select {
+	case exported := <-logExportChannel:
+		if exported != "" {
+			t.Errorf("Expected an empty value to be counted as unknown, got '%s'", exported)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected value to be sent to logExport channel but nothing was received")
+	}
+}
+
 func TestLogsServiceServer_Export_LogRecordAttributes_CounterIncrement(t *testing.T) {
 	ctx := context.Background()
 
@@ -538,3 +576,141 @@ func TestLogsServiceServer_Export_ScopeAttributes_CounterIncrement(t *testing.T)
 		t.Error("Expected scopeAttributeHitCounter to be incremented by 1")
 	}
 }
+
+func TestLogsServiceServer_Export_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	originalTracer := tracer
+	tracer = provider.Tracer("test")
+	defer func() { tracer = originalTracer }()
+
+	server := &dash0LogsServiceServer{
+		addr:         "localhost:4317",
+		attributeKey: "service.name",
+		logExport:    make(chan string, 10),
+	}
+
+	request := createLogRecordAttributesRequest()
+	request.ResourceLogs = append(request.ResourceLogs, createResourceAttributesRequest().ResourceLogs...)
+	if _, err := server.Export(context.Background(), request); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+
+	tests := map[string]struct {
+		span     sdktrace.ReadOnlySpan
+		name     string
+		expected map[attribute.Key]int64
+	}{
+		"Extract": {
+			span: spans[0],
+			name: "extract attribute values",
+			expected: map[attribute.Key]int64{
+				resourceLogsAttribute:     2,
+				logRecordsAttribute:       1,
+				resourceMatchesAttribute:  1,
+				scopeMatchesAttribute:     0,
+				logRecordMatchesAttribute: 1,
+			},
+		},
+		"Enqueue": {
+			span: spans[1],
+			name: "enqueue attribute values",
+			expected: map[attribute.Key]int64{
+				valuesAttribute: 2,
+			},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if test.span.Name() != test.name {
+				t.Errorf("Expected span '%s', got '%s'", test.name, test.span.Name())
+			}
+			attributes := make(map[attribute.Key]attribute.Value)
+			for _, kv := range test.span.Attributes() {
+				attributes[kv.Key] = kv.Value
+			}
+			for key, value := range test.expected {
+				if got, ok := attributes[key]; !ok || got.AsInt64() != value {
+					t.Errorf("Expected %s %d, got %v", key, value, got.Emit())
+				}
+			}
+		})
+	}
+
+	hasWait := false
+	for _, kv := range spans[1].Attributes() {
+		hasWait = hasWait || kv.Key == enqueueWaitAttribute && kv.Value.Type() == attribute.FLOAT64
+	}
+	if !hasWait {
+		t.Errorf("Expected the enqueue wait time, got %v", spans[1].Attributes())
+	}
+}
+
+func TestLogsServiceServer_Export_RecordsAndEnqueueMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	reader := metric.NewManualReader()
+	provider := metric.NewMeterProvider(metric.WithReader(reader))
+	meter := provider.Meter("test")
+
+	counter, err := meter.Int64Counter("com.dash0.homeexercise.logs.records.received")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	histogram, err := meter.Float64Histogram("com.dash0.homeexercise.enqueue.duration")
+	if err != nil {
+		t.Fatalf("Failed to create histogram: %v", err)
+	}
+
+	originalCounter, originalHistogram := logRecordsReceivedCounter, enqueueDurationHistogram
+	logRecordsReceivedCounter, enqueueDurationHistogram = counter, histogram
+	defer func() { logRecordsReceivedCounter, enqueueDurationHistogram = originalCounter, originalHistogram }()
+
+	server := &dash0LogsServiceServer{
+		addr:         "localhost:4317",
+		attributeKey: "service.name",
+		logExport:    make(chan string, 10),
+	}
+
+	request := createLogRecordAttributesRequest()
+	request.ResourceLogs[0].ScopeLogs[0].LogRecords = append(request.ResourceLogs[0].ScopeLogs[0].LogRecords, &otellogs.LogRecord{})
+	if _, err := server.Export(ctx, request); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &resourceMetrics); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+
+	var records int64
+	var enqueues uint64
+	for _, sm := range resourceMetrics.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				for _, dp := range data.DataPoints {
+					records += dp.Value
+				}
+			case metricdata.Histogram[float64]:
+				for _, dp := range data.DataPoints {
+					enqueues += dp.Count
+				}
+			}
+		}
+	}
+
+	if records != 2 {
+		t.Errorf("Expected 2 log records, got %d", records)
+	}
+	if enqueues != 1 {
+		t.Errorf("Expected 1 enqueue duration, got %d", enqueues)
+	}
+}
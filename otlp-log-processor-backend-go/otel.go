@@ -3,9 +3,19 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
@@ -15,14 +25,6 @@ import (
 	"go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.27.0"
-)
-
-var res = resource.NewWithAttributes(
-	semconv.SchemaURL,
-	semconv.ServiceNameKey.String("otlp-log-processor-backend"),
-	semconv.ServiceNamespaceKey.String("dash0-exercise"),
-	semconv.ServiceVersionKey.String("1.0.0"),
 )
 
 // setupOTelSDK bootstraps the OpenTelemetry pipeline.
@@ -51,8 +53,11 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 	prop := newPropagator()
 	otel.SetTextMapPropagator(prop)
 
+	// Set up resource.
+	res := newResource(ctx)
+
 	// Set up trace provider.
-	tracerProvider, err := newTraceProvider()
+	tracerProvider, err := newTraceProvider(ctx, res)
 	if err != nil {
 		handleErr(err)
 		return
@@ -61,7 +66,7 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 	otel.SetTracerProvider(tracerProvider)
 
 	// Set up meter provider.
-	meterProvider, err := newMeterProvider()
+	meterProvider, err := newMeterProvider(ctx, res)
 	if err != nil {
 		handleErr(err)
 		return
@@ -70,7 +75,7 @@ func setupOTelSDK(ctx context.Context) (shutdown func(context.Context) error, er
 	otel.SetMeterProvider(meterProvider)
 
 	// Set up logger provider.
-	loggerProvider, err := newLoggerProvider()
+	loggerProvider, err := newLoggerProvider(ctx, res)
 	if err != nil {
 		handleErr(err)
 		return
@@ -88,47 +93,186 @@ func newPropagator() propagation.TextMapPropagator {
 	)
 }
 
-func newTraceProvider() (*trace.TracerProvider, error) {
-	traceExporter, err := stdouttrace.New(
-		stdouttrace.WithPrettyPrint())
+const (
+	exporterOTLP     = "otlp"
+	exporterStdout   = "stdout"
+	exporterConsole  = "console"
+	exporterNone     = "none"
+	otlpProtocolGRPC = "grpc"
+	otlpProtocolHTTP = "http/protobuf"
+)
+
+// exporters returns the exporters of a signal listed in OTEL_<SIGNAL>_EXPORTER, e.g. OTEL_TRACES_EXPORTER=otlp,stdout.
+// As specified by OpenTelemetry, otlp is used if the variable is not set.
+// "console" is accepted as an alias of stdout, "none" disables the signal.
+// The stdout exporters write to standard error, keeping the stats report on standard output readable.
+func exporters(signal string) ([]string, error) {
+	envKey := "OTEL_" + signal + "_EXPORTER"
+	value := strings.TrimSpace(os.Getenv(envKey))
+	if value == "" {
+		return []string{exporterOTLP}, nil
+	}
+
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		switch name = strings.TrimSpace(name); name {
+		case exporterConsole:
+			name = exporterStdout
+		case exporterOTLP, exporterStdout:
+		case exporterNone:
+			continue
+		default:
+			return nil, fmt.Errorf("%s: unsupported exporter %q, expected %s, %s or %s", envKey, name, exporterOTLP, exporterStdout, exporterNone)
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// otlpProtocol returns the transport of the OTLP exporter of a signal from OTEL_EXPORTER_OTLP_<SIGNAL>_PROTOCOL
+// or OTEL_EXPORTER_OTLP_PROTOCOL, defaulting to http/protobuf as specified by OpenTelemetry.
+// Endpoint, headers, TLS, compression and timeout are read from the OTEL_EXPORTER_OTLP_* variables by the exporters.
+func otlpProtocol(signal string) (string, error) {
+	envKey := "OTEL_EXPORTER_OTLP_" + signal + "_PROTOCOL"
+	protocol := os.Getenv(envKey)
+	if protocol == "" {
+		envKey = "OTEL_EXPORTER_OTLP_PROTOCOL"
+		protocol = os.Getenv(envKey)
+	}
+
+	switch protocol {
+	case "", otlpProtocolHTTP:
+		return otlpProtocolHTTP, nil
+	case otlpProtocolGRPC:
+		return otlpProtocolGRPC, nil
+	default:
+		return "", fmt.Errorf("%s: unsupported protocol %q, expected %s or %s", envKey, protocol, otlpProtocolGRPC, otlpProtocolHTTP)
+	}
+}
+
+func newTraceProvider(ctx context.Context, res *resource.Resource) (*trace.TracerProvider, error) {
+	names, err := exporters("TRACES")
 	if err != nil {
 		return nil, err
 	}
 
-	traceProvider := trace.NewTracerProvider(
+	options := []trace.TracerProviderOption{
 		trace.WithResource(res),
 		trace.WithSampler(trace.AlwaysSample()),
-		trace.WithBatcher(traceExporter,
-			// Default is 5s. Set to 1s for demonstrative purposes.
-			trace.WithBatchTimeout(time.Second)),
-	)
-	return traceProvider, nil
+	}
+	for _, name := range names {
+		switch name {
+		case exporterStdout:
+			traceExporter, err := stdouttrace.New(
+				stdouttrace.WithWriter(os.Stderr),
+				stdouttrace.WithPrettyPrint())
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, trace.WithBatcher(traceExporter,
+				// Default is 5s. Set to 1s for demonstrative purposes.
+				trace.WithBatchTimeout(time.Second)))
+		case exporterOTLP:
+			traceExporter, err := newOTLPTraceExporter(ctx)
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, trace.WithBatcher(traceExporter))
+		}
+	}
+
+	return trace.NewTracerProvider(options...), nil
 }
 
-func newMeterProvider() (*metric.MeterProvider, error) {
-	metricExporter, err := stdoutmetric.New(stdoutmetric.WithPrettyPrint())
+func newOTLPTraceExporter(ctx context.Context) (trace.SpanExporter, error) {
+	protocol, err := otlpProtocol("TRACES")
 	if err != nil {
 		return nil, err
 	}
+	if protocol == otlpProtocolGRPC {
+		return otlptracegrpc.New(ctx)
+	}
+	return otlptracehttp.New(ctx)
+}
 
-	meterProvider := metric.NewMeterProvider(
+func newMeterProvider(ctx context.Context, res *resource.Resource) (*metric.MeterProvider, error) {
+	names, err := exporters("METRICS")
+	if err != nil {
+		return nil, err
+	}
+
+	options := []metric.Option{
 		metric.WithResource(res),
-		metric.WithReader(metric.NewPeriodicReader(metricExporter,
-			// Default is 1m. Set to 10s for demonstrative purposes.
-			metric.WithInterval(10*time.Second))),
-	)
-	return meterProvider, nil
+	}
+	for _, name := range names {
+		switch name {
+		case exporterStdout:
+			metricExporter, err := stdoutmetric.New(stdoutmetric.WithWriter(os.Stderr), stdoutmetric.WithPrettyPrint())
+			if err != nil {
+				return nil, err
+			}
+			options = append(options, metric.WithReader(metric.NewPeriodicReader(metricExporter,
+				// Default is 1m. Set to 10s for demonstrative purposes.
+				metric.WithInterval(10*time.Second))))
+		case exporterOTLP:
+			metricExporter, err := newOTLPMetricExporter(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// The interval is read from OTEL_METRIC_EXPORT_INTERVAL.
+			options = append(options, metric.WithReader(metric.NewPeriodicReader(metricExporter)))
+		}
+	}
+
+	return metric.NewMeterProvider(options...), nil
+}
+
+func newOTLPMetricExporter(ctx context.Context) (metric.Exporter, error) {
+	protocol, err := otlpProtocol("METRICS")
+	if err != nil {
+		return nil, err
+	}
+	if protocol == otlpProtocolGRPC {
+		return otlpmetricgrpc.New(ctx)
+	}
+	return otlpmetrichttp.New(ctx)
 }
 
-func newLoggerProvider() (*log.LoggerProvider, error) {
-	logExporter, err := stdoutlog.New(stdoutlog.WithPrettyPrint())
+func newLoggerProvider(ctx context.Context, res *resource.Resource) (*log.LoggerProvider, error) {
+	names, err := exporters("LOGS")
 	if err != nil {
 		return nil, err
 	}
 
-	loggerProvider := log.NewLoggerProvider(
+	options := []log.LoggerProviderOption{
 		log.WithResource(res),
-		log.WithProcessor(log.NewBatchProcessor(logExporter)),
-	)
-	return loggerProvider, nil
+	}
+	for _, name := range names {
+		var logExporter log.Exporter
+		switch name {
+		case exporterStdout:
+			logExporter, err = stdoutlog.New(stdoutlog.WithWriter(os.Stderr), stdoutlog.WithPrettyPrint())
+		case exporterOTLP:
+			logExporter, err = newOTLPLogExporter(ctx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, log.WithProcessor(log.NewBatchProcessor(logExporter)))
+	}
+
+	return log.NewLoggerProvider(options...), nil
+}
+
+func newOTLPLogExporter(ctx context.Context) (log.Exporter, error) {
+	protocol, err := otlpProtocol("LOGS")
+	if err != nil {
+		return nil, err
+	}
+	if protocol == otlpProtocolGRPC {
+		return otlploggrpc.New(ctx)
+	}
+	return otlploghttp.New(ctx)
 }
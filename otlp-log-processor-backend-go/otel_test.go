@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+func TestExporters(t *testing.T) {
+	tests := map[string]struct {
+		value         string
+		expected      []string
+		expectedError string
+	}{
+		"DefaultsToOTLP": {
+			value:    "",
+			expected: []string{exporterOTLP},
+		},
+		"OTLP": {
+			value:    "otlp",
+			expected: []string{exporterOTLP},
+		},
+		"ConsoleAlias": {
+			value:    "console",
+			expected: []string{exporterStdout},
+		},
+		"List": {
+			value:    "otlp, stdout,console",
+			expected: []string{exporterOTLP, exporterStdout},
+		},
+		"None": {
+			value:    "none",
+			expected: nil,
+		},
+		"Unsupported": {
+			value:         "zipkin",
+			expectedError: `OTEL_TRACES_EXPORTER: unsupported exporter "zipkin"`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Setenv("OTEL_TRACES_EXPORTER", test.value)
+			names, err := exporters("TRACES")
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("Expected error containing '%s', got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exporters failed: %v", err)
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("Expected exporters %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestOTLPProtocol(t *testing.T) {
+	tests := map[string]struct {
+		protocol       string
+		signalProtocol string
+		expected       string
+		expectedError  string
+	}{
+		"DefaultsToHTTP": {
+			expected: otlpProtocolHTTP,
+		},
+		"GRPC": {
+			protocol: "grpc",
+			expected: otlpProtocolGRPC,
+		},
+		"SignalOverridesGeneral": {
+			protocol:       "grpc",
+			signalProtocol: "http/protobuf",
+			expected:       otlpProtocolHTTP,
+		},
+		"Unsupported": {
+			protocol:      "http/json",
+			expectedError: `OTEL_EXPORTER_OTLP_PROTOCOL: unsupported protocol "http/json"`,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", test.protocol)
+			t.Setenv("OTEL_EXPORTER_OTLP_LOGS_PROTOCOL", test.signalProtocol)
+			protocol, err := otlpProtocol("LOGS")
+			if test.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), test.expectedError) {
+					t.Errorf("Expected error containing '%s', got %v", test.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("otlpProtocol failed: %v", err)
+			}
+			if protocol != test.expected {
+				t.Errorf("Expected protocol '%s', got '%s'", test.expected, protocol)
+			}
+		})
+	}
+}
+
+func TestProviders_OTLP(t *testing.T) {
+	for _, signal := range []string{"TRACES", "METRICS", "LOGS"} {
+		t.Setenv("OTEL_"+signal+"_EXPORTER", "otlp")
+	}
+	t.Setenv("OTEL_EXPORTER_OTLP_PROTOCOL", "grpc")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:1")
+	ctx := context.Background()
+
+	tracerProvider, err := newTraceProvider(ctx, resource.Default())
+	if err != nil {
+		t.Fatalf("newTraceProvider failed: %v", err)
+	}
+	meterProvider, err := newMeterProvider(ctx, resource.Default())
+	if err != nil {
+		t.Fatalf("newMeterProvider failed: %v", err)
+	}
+	loggerProvider, err := newLoggerProvider(ctx, resource.Default())
+	if err != nil {
+		t.Fatalf("newLoggerProvider failed: %v", err)
+	}
+
+	// Nothing was recorded, so the shutdown does not need to reach the collector.
+	shutdownCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	_ = tracerProvider.Shutdown(shutdownCtx)
+	_ = meterProvider.Shutdown(shutdownCtx)
+	_ = loggerProvider.Shutdown(shutdownCtx)
+}
+
+func TestProviders_UnsupportedExporter(t *testing.T) {
+	t.Setenv("OTEL_METRICS_EXPORTER", "prometheus")
+
+	if _, err := newMeterProvider(context.Background(), resource.Default()); err == nil {
+		t.Errorf("Expected error for unsupported exporter, got nil")
+	}
+}
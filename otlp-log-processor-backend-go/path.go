@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+// pathSegment is a step of an attribute path: a key within a kvlist or an index within an array.
+type pathSegment struct {
+	key    string
+	index  int
+	array  bool
+	quoted bool
+}
+
+// parseAttributePath parses a path descending into kvlist and array values, e.g. http.request.header["content-type"]
+// or tags[0]. Dots separate keys, a quoted key in brackets may contain dots and brackets itself.
+// As attribute names contain dots, the plain keys of a path are only split where the values require it, see resolveKeyValues.
+func parseAttributePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	rest := path
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, `["`):
+			end := strings.Index(rest[2:], `"]`)
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: unterminated quoted key", path)
+			}
+			segments = append(segments, pathSegment{key: rest[2 : 2+end], quoted: true})
+			rest = rest[2+end+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("path %q: unterminated index", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("path %q: invalid index %q", path, rest[1:end])
+			}
+			segments = append(segments, pathSegment{index: index, array: true})
+			rest = rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path %q: empty key", path)
+			}
+			segments = append(segments, pathSegment{key: rest[:end]})
+			rest = rest[end:]
+		}
+
+		if strings.HasPrefix(rest, ".") {
+			rest = rest[1:]
+			if rest == "" {
+				return nil, fmt.Errorf("path %q: empty key", path)
+			}
+		} else if rest != "" && !strings.HasPrefix(rest, "[") {
+			return nil, fmt.Errorf("path %q: unexpected %q", path, rest)
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("path %q: empty key", path)
+	}
+	return segments, nil
+}
+
+// attributePath parses key as a path, an invalid path is used as a single key.
+func attributePath(key string) []pathSegment {
+	segments, err := parseAttributePath(key)
+	if err != nil {
+		return []pathSegment{{key: key, quoted: true}}
+	}
+	return segments
+}
+
+// resolveKeyValues resolves the path within attributes or the values of a kvlist.
+// Keys may contain dots, so the longest run of plain segments naming a key wins, e.g. the path
+// http.request.header.content-type finds the key content-type within the attribute http.request.header.
+// If the rest of the path cannot be resolved below that key, shorter keys are tried.
+func resolveKeyValues(values []*otelcommon.KeyValue, segments []pathSegment) (*otelcommon.AnyValue, bool) {
+	if len(segments) == 0 || segments[0].array {
+		return nil, false
+	}
+
+	n := 1
+	if !segments[0].quoted {
+		for n < len(segments) && !segments[n].array && !segments[n].quoted {
+			n++
+		}
+	}
+	for ; n > 0; n-- {
+		for _, kv := range values {
+			if keyMatches(kv.Key, segments[:n]) {
+				if value, ok := resolveValue(kv.Value, segments[n:]); ok {
+					return value, true
+				}
+				break
+			}
+		}
+	}
+	return nil, false
+}
+
+// resolveValue resolves the path within value. String values holding a JSON object or array are descended into.
+// At the end of the path an empty value is returned as well, it is counted as unknown like any empty attribute.
+func resolveValue(value *otelcommon.AnyValue, segments []pathSegment) (*otelcommon.AnyValue, bool) {
+	if len(segments) == 0 {
+		return value, true
+	}
+	if value.GetValue() == nil {
+		return nil, false
+	}
+
+	switch v := value.Value.(type) {
+	case *otelcommon.AnyValue_KvlistValue:
+		return resolveKeyValues(v.KvlistValue.GetValues(), segments)
+	case *otelcommon.AnyValue_ArrayValue:
+		elements := v.ArrayValue.GetValues()
+		if !segments[0].array || segments[0].index >= len(elements) {
+			return nil, false
+		}
+		return resolveValue(elements[segments[0].index], segments[1:])
+	case *otelcommon.AnyValue_StringValue:
+		parsed, ok := parseJSON(v.StringValue)
+		if !ok {
+			return nil, false
+		}
+		return resolveValue(parsed, segments)
+	}
+	return nil, false
+}
+
+// keyMatches reports whether key equals the keys of segments joined by dots, without joining them.
+func keyMatches(key string, segments []pathSegment) bool {
+	for i, segment := range segments {
+		if i > 0 {
+			if !strings.HasPrefix(key, ".") {
+				return false
+			}
+			key = key[1:]
+		}
+		if !strings.HasPrefix(key, segment.key) {
+			return false
+		}
+		key = key[len(segment.key):]
+	}
+	return key == ""
+}
+
+// explodeValue returns the elements of an array value, or the value itself.
+func explodeValue(value *otelcommon.AnyValue) []*otelcommon.AnyValue {
+	if array, ok := value.GetValue().(*otelcommon.AnyValue_ArrayValue); ok {
+		return array.ArrayValue.GetValues()
+	}
+	return []*otelcommon.AnyValue{value}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func arrayValue(values ...*otelcommon.AnyValue) *otelcommon.AnyValue {
+	return &otelcommon.AnyValue{Value: &otelcommon.AnyValue_ArrayValue{ArrayValue: &otelcommon.ArrayValue{Values: values}}}
+}
+
+func TestParseAttributePath(t *testing.T) {
+	tests := map[string]struct {
+		path          string
+		expected      []pathSegment
+		expectedError bool
+	}{
+		"Key": {
+			path:     "service.name",
+			expected: []pathSegment{{key: "service"}, {key: "name"}},
+		},
+		"QuotedKey": {
+			path:     `http.request.header["content-type"]`,
+			expected: []pathSegment{{key: "http"}, {key: "request"}, {key: "header"}, {key: "content-type", quoted: true}},
+		},
+		"QuotedKeyWithDots": {
+			path:     `attributes["k8s.pod.name"].short`,
+			expected: []pathSegment{{key: "attributes"}, {key: "k8s.pod.name", quoted: true}, {key: "short"}},
+		},
+		"Index": {
+			path:     "tags[1][0].name",
+			expected: []pathSegment{{key: "tags"}, {index: 1, array: true}, {index: 0, array: true}, {key: "name"}},
+		},
+		"EmptyKey": {
+			path:          "http..status",
+			expectedError: true,
+		},
+		"TrailingDot": {
+			path:          "http.",
+			expectedError: true,
+		},
+		"InvalidIndex": {
+			path:          "tags[x]",
+			expectedError: true,
+		},
+		"UnterminatedIndex": {
+			path:          "tags[0",
+			expectedError: true,
+		},
+		"UnterminatedQuote": {
+			path:          `header["content-type`,
+			expectedError: true,
+		},
+		"MissingDot": {
+			path:          `header["a"]b`,
+			expectedError: true,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			segments, err := parseAttributePath(test.path)
+			if (err != nil) != test.expectedError {
+				t.Fatalf("Expected error %t, got %v", test.expectedError, err)
+			}
+			if !slices.Equal(segments, test.expected) {
+				t.Errorf("Expected %+v, got %+v", test.expected, segments)
+			}
+		})
+	}
+}
+
+func TestResolveKeyValues(t *testing.T) {
+	attributes := []*otelcommon.KeyValue{
+		stringKeyValue("service.name", "checkout"),
+		{Key: "http.request.header", Value: kvlistBody(
+			&otelcommon.KeyValue{Key: "content-type", Value: arrayValue(stringBody("application/json"))},
+			stringKeyValue("x.forwarded.for", "10.0.0.1"),
+		)},
+		{Key: "http", Value: kvlistBody(stringKeyValue("method", "GET"))},
+		{Key: "tags", Value: arrayValue(stringBody("a"), kvlistBody(stringKeyValue("name", "b")))},
+		stringKeyValue("payload", `[{"id": 1}, {"id": 2}]`),
+	}
+
+	tests := map[string]struct {
+		path       string
+		expected   string
+		expectedOK bool
+	}{
+		"DottedKey":             {path: "service.name", expected: "checkout", expectedOK: true},
+		"KvlistValue":           {path: "http.request.header", expected: "{content-type:[application/json],x.forwarded.for:10.0.0.1}", expectedOK: true},
+		"NestedKey":             {path: "http.request.header.content-type", expected: "[application/json]", expectedOK: true},
+		"NestedIndex":           {path: "http.request.header.content-type[0]", expected: "application/json", expectedOK: true},
+		"NestedDottedKey":       {path: "http.request.header.x.forwarded.for", expected: "10.0.0.1", expectedOK: true},
+		"QuotedKey":             {path: `http.request.header["x.forwarded.for"]`, expected: "10.0.0.1", expectedOK: true},
+		"ShorterKeyAfterLonger": {path: "http.method", expected: "GET", expectedOK: true},
+		"ArrayIndex":            {path: "tags[0]", expected: "a", expectedOK: true},
+		"KvlistInArray":         {path: "tags[1].name", expected: "b", expectedOK: true},
+		"IndexOutOfRange":       {path: "tags[2]"},
+		"KeyOnArray":            {path: "tags.name"},
+		"IndexOnKvlist":         {path: "http[0]"},
+		"JSONArrayString":       {path: "payload[1].id", expected: "2", expectedOK: true},
+		"Missing":               {path: "http.status"},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			value, ok := resolveKeyValues(attributes, attributePath(test.path))
+			if ok != test.expectedOK {
+				t.Fatalf("Expected ok %t, got %t", test.expectedOK, ok)
+			}
+			if ok && extractStringValue(value) != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, extractStringValue(value))
+			}
+		})
+	}
+}
+
+func TestValueExtractor_PathAndExplodeArrays(t *testing.T) {
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{
+			{
+				ScopeLogs: []*otellogs.ScopeLogs{
+					{
+						LogRecords: []*otellogs.LogRecord{
+							{Attributes: []*otelcommon.KeyValue{
+								{Key: "http.request.header", Value: kvlistBody(
+									&otelcommon.KeyValue{Key: "accept", Value: arrayValue(stringBody("text/html"), stringBody("application/json"))},
+								)},
+							}},
+							{Attributes: []*otelcommon.KeyValue{
+								{Key: "http.request.header", Value: kvlistBody(stringKeyValue("accept", "*/*"))},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	tests := map[string]struct {
+		extractor valueExtractor
+		expected  []string
+	}{
+		"Path": {
+			extractor: valueExtractor{attributeKey: "http.request.header.accept"},
+			expected:  []string{"[text/html,application/json]", "*/*"},
+		},
+		"ExplodeArrays": {
+			extractor: valueExtractor{attributeKey: "http.request.header.accept", explodeArrays: true},
+			expected:  []string{"text/html", "application/json", "*/*"},
+		},
+		"ExplodeArraysPerRecord": {
+			extractor: valueExtractor{attributeKey: "http.request.header.accept", explodeArrays: true, severity: severitySettings{breakdown: true}},
+			expected:  []string{"text/html [UNSPECIFIED]", "application/json [UNSPECIFIED]", "*/* [UNSPECIFIED]"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			values, hits := test.extractor.extract(context.Background(), request)
+			if !slices.Equal(values, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, values)
+			}
+			if hits.logRecord != 2 {
+				t.Errorf("Expected 2 log record hits, got %d", hits.logRecord)
+			}
+		})
+	}
+}
@@ -1,32 +1,283 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync/atomic"
 	"time"
 )
 
+// windowStats holds the counts of the log values within one duration window.
+type windowStats struct {
+	Start  time.Time
+	End    time.Time
+	Counts map[string]uint64
+}
+
+// processorStats is a copy of the processor state which is safe to use outside the processing loop.
+type processorStats struct {
+	Current windowStats
+	Last    windowStats
+	Total   map[string]uint64
+	// Seen holds when every value was counted first and last, values last counted before StaleBefore are stale.
+	Seen        map[string]valueSeen
+	StaleBefore time.Time
+	// Anomalies are the values whose count in the last window deviated from their baseline.
+	Anomalies []anomaly
+}
+
 type dash0LogsProcessor struct {
 	logStats       map[string]uint64
 	logIntake      <-chan string
 	durationWindow time.Duration
+
+	// distinctValues mirrors len(logStats) for the metrics, which are collected outside the processing loop.
+	distinctValues atomic.Int64
+	// closedWindows counts the closed windows, telling code outside the processing loop when a new window starts.
+	closedWindows atomic.Uint64
+	// lastWindowCounts mirrors lastWindow.Counts for the sampler, the counts of a closed window are not modified.
+	lastWindowCounts atomic.Pointer[map[string]uint64]
+
+	windowStats   map[string]uint64
+	windowStart   time.Time
+	lastWindow    windowStats
+	statsRequests chan chan processorStats
+
+	settingsUpdates chan processorSettings
+
+	snapshotPath     string
+	snapshotInterval time.Duration
+
+	// walSeq is the sequence of the next value in the write-ahead log, it advances with every counted value.
+	wal                   *writeAheadLog
+	walSeq                uint64
+	walCheckpointInterval time.Duration
+
+	// seen holds when every value was counted first and last, values not counted for staleAfter are stale.
+	// staleBefore is the limit of the last check, so every value is only reported once when it becomes stale.
+	seen        map[string]valueSeen
+	staleAfter  time.Duration
+	staleBefore time.Time
+	// staleValues mirrors the number of stale values for the metrics.
+	staleValues atomic.Int64
+
+	// anomalies compares the counts of every closed window against the baselines, lastAnomalies holds its findings.
+	anomalies     *anomalyDetector
+	lastAnomalies []anomaly
+
+	// alerts evaluates the alert rules at every window close, their changes are passed to alertNotifier.
+	alerts        *alertEvaluator
+	alertNotifier *alertNotifier
+
+	shutdown chan struct{}
+	done     chan struct{}
+}
+
+// processorSettings are the settings of the processor which can be changed while it is running.
+type processorSettings struct {
+	durationWindow        time.Duration
+	snapshotInterval      time.Duration
+	walCheckpointInterval time.Duration
+	anomaly               anomalySettings
+	staleAfter            time.Duration
+
+	// alerts holds the changed rules, the processing loop keeps the states of the rules which did not change.
+	alerts       *alertEvaluator
+	alertWebhook alertWebhookConfig
 }
 
 func (lp *dash0LogsProcessor) StartLogProcessing() {
-	ticker := time.NewTicker(lp.durationWindow).C
+	windowTicker := time.NewTicker(lp.durationWindow)
+	defer windowTicker.Stop()
+	if lp.windowStats == nil {
+		lp.windowStats = make(map[string]uint64)
+	}
+	if lp.windowStart.IsZero() {
+		lp.windowStart = time.Now()
+	}
+
+	var snapshotTicker *time.Ticker
+	if lp.snapshotPath != "" {
+		snapshotTicker = time.NewTicker(lp.snapshotInterval)
+		defer snapshotTicker.Stop()
+	}
+
+	// Without snapshots the write-ahead log is checkpointed on its own, otherwise together with the snapshot.
+	var checkpointTicker *time.Ticker
+	if lp.wal != nil && lp.snapshotPath == "" {
+		checkpointTicker = time.NewTicker(lp.walCheckpointInterval)
+		defer checkpointTicker.Stop()
+	}
 
 	for {
 		select {
-		case <-ticker:
+		case now := <-windowTicker.C:
 			fmt.Println("Log stats:")
 			for logValue, count := range lp.logStats {
 				fmt.Printf("%s - %d\n", logValue, count)
 			}
+			lp.closeWindow(now)
+			for _, a := range lp.lastAnomalies {
+				fmt.Printf("Anomaly: %s - %d, expected %.1f ± %.1f\n", a.Value, a.Count, a.Expected, a.StdDev)
+			}
 		case logValue := <-lp.logIntake:
-			if logValue == "" {
-				logValue = "unknown"
+			lp.count(logValue)
+		case reply := <-lp.statsRequests:
+			reply <- lp.stats(time.Now())
+		case now := <-tickerC(snapshotTicker):
+			lp.saveSnapshotAndCheckpoint(now)
+		case <-tickerC(checkpointTicker):
+			lp.checkpoint()
+		case settings := <-lp.settingsUpdates:
+			if settings.durationWindow != lp.durationWindow {
+				lp.durationWindow = settings.durationWindow
+				windowTicker.Reset(lp.durationWindow)
+			}
+			if snapshotTicker != nil && settings.snapshotInterval != lp.snapshotInterval {
+				lp.snapshotInterval = settings.snapshotInterval
+				snapshotTicker.Reset(lp.snapshotInterval)
+			}
+			if checkpointTicker != nil && settings.walCheckpointInterval != lp.walCheckpointInterval {
+				lp.walCheckpointInterval = settings.walCheckpointInterval
+				checkpointTicker.Reset(lp.walCheckpointInterval)
 			}
+			lp.anomalies = lp.anomalies.withSettings(settings.anomaly)
+			lp.staleAfter = settings.staleAfter
+			lp.alerts = settings.alerts.withStates(lp.alerts)
+			if lp.alertNotifier != nil {
+				lp.alertNotifier.setWebhook(settings.alertWebhook.URL, settings.alertWebhook.Timeout)
+			}
+		case <-lp.shutdown:
+			lp.drainIntake()
+			if lp.snapshotPath != "" {
+				lp.saveSnapshotAndCheckpoint(time.Now())
+			} else {
+				lp.checkpoint()
+			}
+			close(lp.done)
+			return
+		}
+	}
+}
+
+// tickerC returns the channel of ticker, or nil which blocks forever if the ticker is disabled.
+func tickerC(ticker *time.Ticker) <-chan time.Time {
+	if ticker == nil {
+		return nil
+	}
+	return ticker.C
+}
 
-			lp.logStats[logValue]++
+// UpdateSettings hands changed settings to the processing loop.
+// A changed window duration takes effect from the next tick on, the current window keeps its counts.
+func (lp *dash0LogsProcessor) UpdateSettings(ctx context.Context, settings processorSettings) error {
+	select {
+	case lp.settingsUpdates <- settings:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drainIntake counts the values which are still buffered in the intake channel.
+func (lp *dash0LogsProcessor) drainIntake() {
+	for {
+		select {
+		case logValue := <-lp.logIntake:
+			lp.count(logValue)
+		default:
+			return
+		}
+	}
+}
+
+func (lp *dash0LogsProcessor) count(logValue string) {
+	if logValue == "" {
+		logValue = "unknown"
+	}
+
+	lp.logStats[logValue]++
+	lp.markSeen(logValue, time.Now())
+	lp.windowStats[logValue]++
+	lp.walSeq++
+	lp.distinctValues.Store(int64(len(lp.logStats)))
+}
+
+// checkpoint marks the counted values as processed in the write-ahead log.
+func (lp *dash0LogsProcessor) checkpoint() {
+	if lp.wal == nil {
+		return
+	}
+	if err := lp.wal.checkpoint(lp.walSeq); err != nil {
+		slog.Error("Failed to checkpoint write-ahead log", slog.Any("error", err))
+	}
+}
+
+// Stop ends the processing after counting the buffered values and writing a final snapshot.
+// It must only be called once and after no more values are sent to the intake channel.
+func (lp *dash0LogsProcessor) Stop() {
+	close(lp.shutdown)
+	<-lp.done
+}
+
+func (lp *dash0LogsProcessor) closeWindow(now time.Time) {
+	previous := lp.lastWindow.Counts
+	lp.lastWindow = windowStats{
+		Start:  lp.windowStart,
+		End:    now,
+		Counts: lp.windowStats,
+	}
+	lp.windowStats = make(map[string]uint64)
+	lp.windowStart = now
+	lastCounts := lp.lastWindow.Counts
+	lp.lastWindowCounts.Store(&lastCounts)
+	lp.closedWindows.Add(1)
+
+	lp.checkStale(now)
+
+	lp.lastAnomalies = nil
+	if lp.anomalies != nil {
+		lp.lastAnomalies = lp.anomalies.detect(lp.lastWindow.Counts, lp.logStats)
+	}
+	if lp.alerts != nil && lp.alertNotifier != nil {
+		for _, event := range lp.alerts.evaluate(lp.lastWindow, previous, lp.logStats) {
+			lp.alertNotifier.notify(event)
 		}
 	}
 }
+
+func (lp *dash0LogsProcessor) stats(now time.Time) processorStats {
+	return processorStats{
+		Current: windowStats{
+			Start:  lp.windowStart,
+			End:    now,
+			Counts: maps.Clone(lp.windowStats),
+		},
+		Last:        lp.lastWindow,
+		Total:       maps.Clone(lp.logStats),
+		Anomalies:   slices.Clone(lp.lastAnomalies),
+		Seen:        maps.Clone(lp.seen),
+		StaleBefore: lp.staleBeforeAt(now),
+	}
+}
+
+// Stats asks the processing loop for a copy of its state.
+// The maps of the processor are only accessed from within the loop, so no locking is required.
+func (lp *dash0LogsProcessor) Stats(ctx context.Context) (processorStats, error) {
+	reply := make(chan processorStats, 1)
+	select {
+	case lp.statsRequests <- reply:
+	case <-ctx.Done():
+		return processorStats{}, ctx.Err()
+	}
+
+	select {
+	case stats := <-reply:
+		return stats, nil
+	case <-ctx.Done():
+		return processorStats{}, ctx.Err()
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
@@ -52,3 +53,72 @@ func TestDash0LogsProcessor_TwoLogEntriesOutput(t *testing.T) {
 		t.Error("Expected 'info-log - 1' in output")
 	}
 }
+
+func TestDash0LogsProcessor_Stats(t *testing.T) {
+	// Unbuffered, so every value is counted once the send returns.
+	logIntake := make(chan string)
+	processor := &dash0LogsProcessor{
+		logStats:       make(map[string]uint64),
+		logIntake:      logIntake,
+		durationWindow: time.Hour,
+		statsRequests:  make(chan chan processorStats),
+	}
+
+	go processor.StartLogProcessing()
+
+	logIntake <- "error-log"
+	logIntake <- ""
+
+	stats, err := processor.Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+
+	if stats.Current.Counts["error-log"] != 1 || stats.Current.Counts["unknown"] != 1 {
+		t.Errorf("Expected current window to count 'error-log' and 'unknown' once, got %v", stats.Current.Counts)
+	}
+	if stats.Total["error-log"] != 1 {
+		t.Errorf("Expected total count of 1 for 'error-log', got %d", stats.Total["error-log"])
+	}
+	if len(stats.Last.Counts) != 0 {
+		t.Errorf("Expected no completed window yet, got %v", stats.Last.Counts)
+	}
+}
+
+func TestDash0LogsProcessor_CloseWindow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	processor := &dash0LogsProcessor{
+		logStats:    map[string]uint64{"error-log": 3},
+		windowStats: map[string]uint64{"error-log": 2},
+		windowStart: start,
+	}
+
+	processor.closeWindow(start.Add(10 * time.Second))
+
+	stats := processor.stats(start.Add(12 * time.Second))
+	if stats.Last.Counts["error-log"] != 2 || !stats.Last.Start.Equal(start) || !stats.Last.End.Equal(start.Add(10*time.Second)) {
+		t.Errorf("Expected last window to hold the closed counts, got %+v", stats.Last)
+	}
+	if len(stats.Current.Counts) != 0 || !stats.Current.Start.Equal(start.Add(10*time.Second)) {
+		t.Errorf("Expected a new empty current window, got %+v", stats.Current)
+	}
+	if stats.Total["error-log"] != 3 {
+		t.Errorf("Expected total to be kept, got %v", stats.Total)
+	}
+	if lastCounts := processor.lastWindowCounts.Load(); lastCounts == nil || (*lastCounts)["error-log"] != 2 || processor.closedWindows.Load() != 1 {
+		t.Errorf("Expected the last window counts to be published, got %v after %d windows", lastCounts, processor.closedWindows.Load())
+	}
+}
+
+func TestDash0LogsProcessor_StatsCancelled(t *testing.T) {
+	processor := &dash0LogsProcessor{
+		statsRequests: make(chan chan processorStats),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := processor.Stats(ctx); err == nil {
+		t.Error("Expected an error when the processing loop does not answer")
+	}
+}
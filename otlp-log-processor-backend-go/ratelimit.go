@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+const unknownClient = "unknown"
+
+// defaultRateLimitBurst is the smallest default bucket size, the default batch size of the OpenTelemetry Collector,
+// so the usual batches of a client are admitted also with low rate limits.
+const defaultRateLimitBurst = 8192
+
+// clientRateLimiter keeps a token bucket per client, measured in log records per second.
+// A client is identified by the tenant header if configured and present, otherwise by its peer address.
+type clientRateLimiter struct {
+	recordsPerSecond rate.Limit
+	burst            int
+	tenantHeader     string
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	now      func() time.Time
+}
+
+func newClientRateLimiter(recordsPerSecond float64, burst int, tenantHeader string) *clientRateLimiter {
+	rl := &clientRateLimiter{
+		limiters: make(map[string]*rate.Limiter),
+		now:      time.Now,
+	}
+	rl.setLimits(recordsPerSecond, burst, tenantHeader)
+	return rl
+}
+
+// setLimits changes the limits while keeping the buckets of the known clients.
+func (rl *clientRateLimiter) setLimits(recordsPerSecond float64, burst int, tenantHeader string) {
+	if burst <= 0 {
+		burst = max(int(recordsPerSecond), defaultRateLimitBurst)
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.recordsPerSecond = rate.Limit(recordsPerSecond)
+	rl.burst = burst
+	rl.tenantHeader = tenantHeader
+	now := rl.now()
+	for _, limiter := range rl.limiters {
+		limiter.SetLimitAt(now, rl.recordsPerSecond)
+		limiter.SetBurstAt(now, rl.burst)
+	}
+}
+
+// allow takes logRecords tokens from the bucket of the calling client.
+// If the bucket does not hold enough tokens, a ResourceExhausted status carrying RetryInfo is returned.
+// An export exceeding the bucket size is charged as a full bucket, so it is admitted once the bucket is full.
+func (rl *clientRateLimiter) allow(ctx context.Context, logRecords int) error {
+	if logRecords == 0 {
+		return nil
+	}
+
+	rl.mu.Lock()
+	client := rl.clientKey(ctx)
+	now := rl.now()
+	recordsPerSecond, burst := rl.recordsPerSecond, rl.burst
+	limiter, ok := rl.limiters[client]
+	if !ok {
+		rl.evictIdle(now)
+		limiter = rate.NewLimiter(recordsPerSecond, burst)
+		rl.limiters[client] = limiter
+	}
+	rl.mu.Unlock()
+
+	reservation := limiter.ReserveN(now, min(logRecords, burst))
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return rl.throttled(logRecords, delay,
+			fmt.Sprintf("rate limit of %g log records per second exceeded for client %q", float64(recordsPerSecond), client))
+	}
+
+	return nil
+}
+
+func (rl *clientRateLimiter) throttled(logRecords int, retryDelay time.Duration, message string) error {
+	throttledLogsCounter.Add(context.Background(), int64(logRecords))
+
+	st, err := status.New(codes.ResourceExhausted, message).WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(retryDelay),
+	})
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, message)
+	}
+	return st.Err()
+}
+
+// evictIdle drops the limiters whose bucket is full again.
+// Recreating such a limiter later is indistinguishable from keeping it, so the map only holds active clients.
+// The caller must hold rl.mu.
+func (rl *clientRateLimiter) evictIdle(now time.Time) {
+	for client, limiter := range rl.limiters {
+		if limiter.TokensAt(now) >= float64(rl.burst) {
+			delete(rl.limiters, client)
+		}
+	}
+}
+
+// clientKey identifies the client of the request. The caller must hold rl.mu.
+func (rl *clientRateLimiter) clientKey(ctx context.Context) string {
+	if rl.tenantHeader != "" {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if tenants := md.Get(rl.tenantHeader); len(tenants) > 0 && tenants[0] != "" {
+				return "tenant:" + tenants[0]
+			}
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return unknownClient
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return "peer:" + p.Addr.String()
+	}
+	return "peer:" + host
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerContext(ip string, port int) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: port},
+	})
+}
+
+func TestClientRateLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		requests        []int
+		ctx             context.Context
+		expectedAllowed []bool
+	}{
+		"WithinLimit": {
+			requests:        []int{4, 6},
+			ctx:             peerContext("10.0.0.1", 1234),
+			expectedAllowed: []bool{true, true},
+		},
+		"ExceedsLimit": {
+			requests:        []int{8, 3},
+			ctx:             peerContext("10.0.0.1", 1234),
+			expectedAllowed: []bool{true, false},
+		},
+		"ExceedsBurst": {
+			requests:        []int{11, 1},
+			ctx:             peerContext("10.0.0.1", 1234),
+			expectedAllowed: []bool{true, false},
+		},
+		"EmptyRequest": {
+			requests:        []int{10, 0},
+			ctx:             peerContext("10.0.0.1", 1234),
+			expectedAllowed: []bool{true, true},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limiter := newClientRateLimiter(10, 10, "")
+			limiter.now = func() time.Time { return now }
+
+			for i, logRecords := range test.requests {
+				err := limiter.allow(test.ctx, logRecords)
+				if allowed := err == nil; allowed != test.expectedAllowed[i] {
+					t.Errorf("Request %d: expected allowed %t, got error %v", i, test.expectedAllowed[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestClientRateLimiter_ResourceExhaustedWithRetryInfo(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newClientRateLimiter(10, 10, "")
+	limiter.now = func() time.Time { return now }
+	ctx := peerContext("10.0.0.1", 1234)
+
+	if err := limiter.allow(ctx, 10); err != nil {
+		t.Fatalf("Expected first request to be allowed, got %v", err)
+	}
+
+	err := limiter.allow(ctx, 5)
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %v", err)
+	}
+
+	var retryInfo *errdetails.RetryInfo
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok {
+			retryInfo = info
+		}
+	}
+	if retryInfo == nil {
+		t.Fatal("Expected RetryInfo in status details")
+	}
+	if delay := retryInfo.GetRetryDelay().AsDuration(); delay != 500*time.Millisecond {
+		t.Errorf("Expected retry delay of 500ms, got %s", delay)
+	}
+}
+
+func TestClientRateLimiter_ExceedsBurst(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newClientRateLimiter(10, 10, "")
+	limiter.now = func() time.Time { return now }
+	ctx := peerContext("10.0.0.1", 1234)
+
+	if err := limiter.allow(ctx, 25); err != nil {
+		t.Fatalf("Expected an export exceeding the burst to be admitted with a full bucket, got %v", err)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	err := limiter.allow(ctx, 25)
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted until the bucket is full again, got %v", err)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay().AsDuration() != 500*time.Millisecond {
+			t.Errorf("Expected retry delay of 500ms, got %s", info.GetRetryDelay().AsDuration())
+		}
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if err := limiter.allow(ctx, 25); err != nil {
+		t.Errorf("Expected the export to be admitted once the bucket is full again, got %v", err)
+	}
+}
+
+func TestClientRateLimiter_DefaultBurst(t *testing.T) {
+	tests := map[string]struct {
+		recordsPerSecond float64
+		expectedBurst    int
+	}{
+		"LowRate": {
+			recordsPerSecond: 100,
+			expectedBurst:    defaultRateLimitBurst,
+		},
+		"HighRate": {
+			recordsPerSecond: 20000,
+			expectedBurst:    20000,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limiter := newClientRateLimiter(test.recordsPerSecond, 0, "")
+			if limiter.burst != test.expectedBurst {
+				t.Errorf("Expected burst %d, got %d", test.expectedBurst, limiter.burst)
+			}
+			if err := limiter.allow(peerContext("10.0.0.1", 1234), 512); err != nil {
+				t.Errorf("Expected a batch of 512 log records to be admitted, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClientRateLimiter_ClientKey(t *testing.T) {
+	tests := map[string]struct {
+		tenantHeader string
+		ctx          context.Context
+		expected     string
+	}{
+		"PeerWithoutPort": {
+			ctx:      peerContext("10.0.0.1", 1234),
+			expected: "peer:10.0.0.1",
+		},
+		"TenantHeader": {
+			tenantHeader: "x-tenant",
+			ctx:          metadata.NewIncomingContext(peerContext("10.0.0.1", 1234), metadata.Pairs("x-tenant", "checkout")),
+			expected:     "tenant:checkout",
+		},
+		"TenantHeaderMissing": {
+			tenantHeader: "x-tenant",
+			ctx:          peerContext("10.0.0.1", 1234),
+			expected:     "peer:10.0.0.1",
+		},
+		"NoPeer": {
+			ctx:      context.Background(),
+			expected: unknownClient,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limiter := newClientRateLimiter(10, 10, test.tenantHeader)
+			if key := limiter.clientKey(test.ctx); key != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, key)
+			}
+		})
+	}
+}
+
+func TestClientRateLimiter_SeparateBuckets(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newClientRateLimiter(10, 10, "")
+	limiter.now = func() time.Time { return now }
+
+	if err := limiter.allow(peerContext("10.0.0.1", 1234), 10); err != nil {
+		t.Errorf("Expected first client to be allowed, got %v", err)
+	}
+	if err := limiter.allow(peerContext("10.0.0.2", 1234), 10); err != nil {
+		t.Errorf("Expected second client to be allowed, got %v", err)
+	}
+	if err := limiter.allow(peerContext("10.0.0.1", 4321), 1); err == nil {
+		t.Error("Expected first client to be throttled on a new connection")
+	}
+}
+
+func TestClientRateLimiter_EvictIdle(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newClientRateLimiter(10, 10, "")
+	limiter.now = func() time.Time { return now }
+
+	_ = limiter.allow(peerContext("10.0.0.1", 1234), 10)
+	now = now.Add(2 * time.Second)
+	_ = limiter.allow(peerContext("10.0.0.2", 1234), 10)
+
+	if _, ok := limiter.limiters["peer:10.0.0.1"]; ok {
+		t.Error("Expected refilled limiter of idle client to be evicted")
+	}
+	if _, ok := limiter.limiters["peer:10.0.0.2"]; !ok {
+		t.Error("Expected limiter of active client to be kept")
+	}
+}
+
+func TestLogsServiceServer_Export_RateLimited(t *testing.T) {
+	logExportChannel := make(chan string, 10)
+	server := &dash0LogsServiceServer{
+		addr:         "localhost:4317",
+		attributeKey: "service.name",
+		logExport:    logExportChannel,
+		rateLimiter:  newClientRateLimiter(1, 1, ""),
+	}
+
+	_, err := server.Export(peerContext("10.0.0.1", 1234), createLogRecordAttributesRequest())
+	if err != nil {
+		t.Errorf("Export failed: %v", err)
+	}
+
+	_, err = server.Export(peerContext("10.0.0.1", 1234), createLogRecordAttributesRequest())
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted, got %v", err)
+	}
+
+	if len(logExportChannel) != 1 {
+		t.Errorf("Expected only the allowed export to be enqueued, got %d values", len(logExportChannel))
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+)
+
+const (
+	// renderingLegacy renders values with extractStringValue.
+	renderingLegacy = "legacy"
+	// renderingCanonical renders values unambiguously, see canonicalString.
+	renderingCanonical = "canonical"
+)
+
+// valueRenderer converts attribute values into the strings which are counted.
+type valueRenderer struct {
+	canonical bool
+	// typed prefixes the values with their type, e.g. int:42 and string:42.
+	typed bool
+}
+
+func newValueRenderer(rendering string, typed bool) (valueRenderer, error) {
+	switch rendering {
+	case "", renderingLegacy:
+		return valueRenderer{typed: typed}, nil
+	case renderingCanonical:
+		return valueRenderer{canonical: true, typed: typed}, nil
+	default:
+		return valueRenderer{}, fmt.Errorf("unknown rendering %q, expected %s or %s", rendering, renderingLegacy, renderingCanonical)
+	}
+}
+
+func (r valueRenderer) render(value *otelcommon.AnyValue) string {
+	if r.canonical {
+		return canonicalString(value)
+	}
+	return extractStringValue(value)
+}
+
+// label prefixes a rendered value with its type if values are typed.
+func (r valueRenderer) label(text string, valueType string) string {
+	if !r.typed {
+		return text
+	}
+	return valueType + ":" + text
+}
+
+// valueType names the type of value as used by the typed rendering.
+func valueType(value *otelcommon.AnyValue) string {
+	switch value.GetValue().(type) {
+	case *otelcommon.AnyValue_StringValue:
+		return "string"
+	case *otelcommon.AnyValue_BoolValue:
+		return "bool"
+	case *otelcommon.AnyValue_IntValue:
+		return "int"
+	case *otelcommon.AnyValue_DoubleValue:
+		return "double"
+	case *otelcommon.AnyValue_BytesValue:
+		return "bytes"
+	case *otelcommon.AnyValue_ArrayValue:
+		return "array"
+	case *otelcommon.AnyValue_KvlistValue:
+		return "kvlist"
+	default:
+		return "empty"
+	}
+}
+
+// canonicalString renders value unambiguously: doubles in their shortest form which parses back to the same value,
+// bytes in standard base64, and arrays and kvlists as JSON, keeping the order of the kvlist entries.
+func canonicalString(value *otelcommon.AnyValue) string {
+	switch v := value.GetValue().(type) {
+	case *otelcommon.AnyValue_StringValue:
+		return v.StringValue
+	case *otelcommon.AnyValue_BoolValue:
+		return strconv.FormatBool(v.BoolValue)
+	case *otelcommon.AnyValue_IntValue:
+		return strconv.FormatInt(v.IntValue, 10)
+	case *otelcommon.AnyValue_DoubleValue:
+		return formatDouble(v.DoubleValue)
+	case *otelcommon.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *otelcommon.AnyValue_ArrayValue, *otelcommon.AnyValue_KvlistValue:
+		var b bytes.Buffer
+		appendCanonicalJSON(&b, value)
+		return b.String()
+	default:
+		return ""
+	}
+}
+
+func formatDouble(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// appendCanonicalJSON writes value as JSON. Doubles which JSON cannot represent, NaN and infinities, become strings.
+func appendCanonicalJSON(b *bytes.Buffer, value *otelcommon.AnyValue) {
+	switch v := value.GetValue().(type) {
+	case *otelcommon.AnyValue_StringValue:
+		appendJSONString(b, v.StringValue)
+	case *otelcommon.AnyValue_BoolValue:
+		b.WriteString(strconv.FormatBool(v.BoolValue))
+	case *otelcommon.AnyValue_IntValue:
+		b.WriteString(strconv.FormatInt(v.IntValue, 10))
+	case *otelcommon.AnyValue_DoubleValue:
+		if math.IsNaN(v.DoubleValue) || math.IsInf(v.DoubleValue, 0) {
+			appendJSONString(b, formatDouble(v.DoubleValue))
+		} else {
+			b.WriteString(formatDouble(v.DoubleValue))
+		}
+	case *otelcommon.AnyValue_BytesValue:
+		appendJSONString(b, base64.StdEncoding.EncodeToString(v.BytesValue))
+	case *otelcommon.AnyValue_ArrayValue:
+		b.WriteByte('[')
+		for i, element := range v.ArrayValue.GetValues() {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			appendCanonicalJSON(b, element)
+		}
+		b.WriteByte(']')
+	case *otelcommon.AnyValue_KvlistValue:
+		b.WriteByte('{')
+		for i, kv := range v.KvlistValue.GetValues() {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			appendJSONString(b, kv.Key)
+			b.WriteByte(':')
+			appendCanonicalJSON(b, kv.Value)
+		}
+		b.WriteByte('}')
+	default:
+		b.WriteString("null")
+	}
+}
+
+func appendJSONString(b *bytes.Buffer, s string) {
+	encoder := json.NewEncoder(b)
+	encoder.SetEscapeHTML(false)
+	// Encoding a string cannot fail.
+	_ = encoder.Encode(s)
+	b.Truncate(b.Len() - len("\n"))
+}
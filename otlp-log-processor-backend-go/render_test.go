@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otelresource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestCanonicalString(t *testing.T) {
+	tests := map[string]struct {
+		value    *otelcommon.AnyValue
+		expected string
+	}{
+		"String": {
+			value:    stringBody("checkout"),
+			expected: "checkout",
+		},
+		"Int": {
+			value:    &otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: -42}},
+			expected: "-42",
+		},
+		"Bool": {
+			value:    &otelcommon.AnyValue{Value: &otelcommon.AnyValue_BoolValue{BoolValue: true}},
+			expected: "true",
+		},
+		"Double": {
+			value:    &otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: 0.1}},
+			expected: "0.1",
+		},
+		"DoubleWhole": {
+			value:    &otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: 100}},
+			expected: "100",
+		},
+		"DoubleSmall": {
+			value:    &otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: 1e-9}},
+			expected: "1e-09",
+		},
+		"DoubleNaN": {
+			value:    &otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: math.NaN()}},
+			expected: "NaN",
+		},
+		"Bytes": {
+			value:    &otelcommon.AnyValue{Value: &otelcommon.AnyValue_BytesValue{BytesValue: []byte{0xff, 0x00, 'a'}}},
+			expected: "/wBh",
+		},
+		"Array": {
+			value: arrayValue(
+				stringBody(`say "hi"`),
+				&otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: 1}},
+				&otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: math.Inf(1)}},
+				&otelcommon.AnyValue{},
+			),
+			expected: `["say \"hi\"",1,"+Inf",null]`,
+		},
+		"Kvlist": {
+			value: kvlistBody(
+				stringKeyValue("z", "<b>"),
+				&otelcommon.KeyValue{Key: "a", Value: arrayValue(&otelcommon.AnyValue{Value: &otelcommon.AnyValue_BoolValue{}})},
+			),
+			expected: `{"z":"<b>","a":[false]}`,
+		},
+		"Empty": {
+			value:    &otelcommon.AnyValue{},
+			expected: "",
+		},
+		"Nil": {
+			expected: "",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if got := canonicalString(test.value); got != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestValueRenderer(t *testing.T) {
+	intValue := &otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: 42}}
+	doubleValue := &otelcommon.AnyValue{Value: &otelcommon.AnyValue_DoubleValue{DoubleValue: 1.5}}
+
+	tests := map[string]struct {
+		rendering string
+		typed     bool
+		value     *otelcommon.AnyValue
+		expected  string
+	}{
+		"LegacyDouble": {
+			rendering: renderingLegacy,
+			value:     doubleValue,
+			expected:  "1.500000",
+		},
+		"CanonicalDouble": {
+			rendering: renderingCanonical,
+			value:     doubleValue,
+			expected:  "1.5",
+		},
+		"TypedInt": {
+			rendering: renderingCanonical,
+			typed:     true,
+			value:     intValue,
+			expected:  "int:42",
+		},
+		"TypedString": {
+			rendering: renderingCanonical,
+			typed:     true,
+			value:     stringBody("42"),
+			expected:  "string:42",
+		},
+		"TypedLegacy": {
+			typed:    true,
+			value:    doubleValue,
+			expected: "double:1.500000",
+		},
+		"TypedEmpty": {
+			rendering: renderingCanonical,
+			typed:     true,
+			value:     &otelcommon.AnyValue{},
+			expected:  "empty:",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			renderer, err := newValueRenderer(test.rendering, test.typed)
+			if err != nil {
+				t.Fatalf("newValueRenderer failed: %v", err)
+			}
+			if got := renderer.label(renderer.render(test.value), valueType(test.value)); got != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewValueRenderer_Unknown(t *testing.T) {
+	if _, err := newValueRenderer("json", false); err == nil {
+		t.Errorf("Expected error for unknown rendering, got nil")
+	}
+}
+
+func TestValueExtractor_Extract_Typed(t *testing.T) {
+	renderer, _ := newValueRenderer(renderingCanonical, true)
+	filter, err := newValueFilter([]filterRule{{Exact: []string{"42"}}}, nil, nil)
+	if err != nil {
+		t.Fatalf("newValueFilter failed: %v", err)
+	}
+	extractor := valueExtractor{attributeKey: "code", renderer: renderer, filter: filter}
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			Resource: &otelresource.Resource{Attributes: []*otelcommon.KeyValue{
+				{Key: "code", Value: &otelcommon.AnyValue{Value: &otelcommon.AnyValue_IntValue{IntValue: 42}}},
+			}},
+		}},
+	}
+
+	values, _ := extractor.extract(context.Background(), request)
+	if len(values) != 1 || values[0] != "int:42" {
+		t.Errorf("Expected [int:42], got %v", values)
+	}
+}
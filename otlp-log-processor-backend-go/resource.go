@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	serviceName      = "otlp-log-processor-backend"
+	serviceNamespace = "dash0-exercise"
+)
+
+// newResource describes the processor in its own telemetry.
+// The built-in service attributes are merged with the detected host, OS, process and container attributes,
+// and finally with OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME, so every setting can be overridden per deployment.
+// A random service.instance.id keeps replicas apart even when they share a host name.
+//
+// Detection failures are logged and the partially detected resource is used.
+func newResource(ctx context.Context) *resource.Resource {
+	res, err := resource.New(ctx,
+		resource.WithSchemaURL(semconv.SchemaURL),
+		resource.WithAttributes(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceNamespace(serviceNamespace),
+			semconv.ServiceVersion(buildVersion(debug.ReadBuildInfo())),
+			semconv.ServiceInstanceID(newInstanceID()),
+		),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+		resource.WithOS(),
+		// The command line arguments are left out, they may contain secrets.
+		resource.WithProcessPID(),
+		resource.WithProcessExecutableName(),
+		resource.WithProcessOwner(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithContainer(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		slog.Warn("Failed to detect all resource attributes", slog.Any("error", err))
+	}
+	return res
+}
+
+// buildVersion returns the module version of the binary, or the VCS revision for development builds.
+func buildVersion(info *debug.BuildInfo, ok bool) string {
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision == "" {
+		return "devel"
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	if modified == "true" {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// newInstanceID returns a random UUID version 4.
+func newInstanceID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
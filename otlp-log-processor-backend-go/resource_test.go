@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"runtime/debug"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestBuildVersion(t *testing.T) {
+	tests := map[string]struct {
+		info     *debug.BuildInfo
+		ok       bool
+		expected string
+	}{
+		"NoBuildInfo": {
+			ok:       false,
+			expected: "unknown",
+		},
+		"ModuleVersion": {
+			info:     &debug.BuildInfo{Main: debug.Module{Version: "v1.2.3"}},
+			ok:       true,
+			expected: "v1.2.3",
+		},
+		"Revision": {
+			info: &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}, Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+				{Key: "vcs.modified", Value: "false"},
+			}},
+			ok:       true,
+			expected: "0123456789ab",
+		},
+		"ModifiedRevision": {
+			info: &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}, Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+				{Key: "vcs.modified", Value: "true"},
+			}},
+			ok:       true,
+			expected: "0123456789ab-dirty",
+		},
+		"Devel": {
+			info:     &debug.BuildInfo{Main: debug.Module{Version: "(devel)"}},
+			ok:       true,
+			expected: "devel",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if got := buildVersion(test.info, test.ok); got != test.expected {
+				t.Errorf("Expected version '%s', got '%s'", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewResource(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "")
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "deployment.environment=test,service.namespace=custom")
+
+	res := newResource(context.Background())
+	attributes := make(map[attribute.Key]string)
+	for _, kv := range res.Attributes() {
+		attributes[kv.Key] = kv.Value.Emit()
+	}
+
+	expected := map[attribute.Key]string{
+		semconv.ServiceNameKey:      serviceName,
+		semconv.ServiceNamespaceKey: "custom",
+		"deployment.environment":    "test",
+	}
+	for key, value := range expected {
+		if attributes[key] != value {
+			t.Errorf("Expected %s '%s', got '%s'", key, value, attributes[key])
+		}
+	}
+	for _, key := range []attribute.Key{semconv.ServiceVersionKey, semconv.HostNameKey, semconv.ProcessPIDKey} {
+		if attributes[key] == "" {
+			t.Errorf("Expected %s to be detected", key)
+		}
+	}
+	if _, ok := attributes[semconv.ProcessCommandArgsKey]; ok {
+		t.Errorf("Expected no %s", semconv.ProcessCommandArgsKey)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(attributes[semconv.ServiceInstanceIDKey]) {
+		t.Errorf("Expected a UUID service.instance.id, got '%s'", attributes[semconv.ServiceInstanceIDKey])
+	}
+}
+
+func TestNewResource_ServiceNameFromEnv(t *testing.T) {
+	t.Setenv("OTEL_SERVICE_NAME", "log-processor-eu")
+
+	res := newResource(context.Background())
+	if name, _ := res.Set().Value(semconv.ServiceNameKey); name.AsString() != "log-processor-eu" {
+		t.Errorf("Expected service name 'log-processor-eu', got '%s'", name.AsString())
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// valueSeen holds when a value was counted first and last.
+type valueSeen struct {
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// stale reports whether the value was last counted before staleBefore, a zero staleBefore disables staleness.
+func (s valueSeen) stale(staleBefore time.Time) bool {
+	return !staleBefore.IsZero() && s.LastSeen.Before(staleBefore)
+}
+
+// markSeen records that logValue was counted at now, announcing values which were never counted before.
+func (lp *dash0LogsProcessor) markSeen(logValue string, now time.Time) {
+	if lp.seen == nil {
+		lp.seen = make(map[string]valueSeen)
+	}
+	seen, ok := lp.seen[logValue]
+	if !ok {
+		seen.FirstSeen = now
+		slog.Info("New value", slog.String("value", logValue), slog.Time("firstSeen", now))
+		// The value is only logged, as an attribute every value would create a series of its own.
+		newValuesCounter.Add(context.Background(), 1)
+	}
+	seen.LastSeen = now
+	lp.seen[logValue] = seen
+}
+
+// staleBeforeAt returns the time before which values last counted are stale at now, or zero if staleness is disabled.
+func (lp *dash0LogsProcessor) staleBeforeAt(now time.Time) time.Time {
+	if lp.staleAfter <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-lp.staleAfter)
+}
+
+// checkStale counts the stale values at now and logs the values which became stale since the last check.
+func (lp *dash0LogsProcessor) checkStale(now time.Time) {
+	staleBefore := lp.staleBeforeAt(now)
+	var stale int64
+	for logValue, seen := range lp.seen {
+		if !seen.stale(staleBefore) {
+			continue
+		}
+		stale++
+		if !seen.stale(lp.staleBefore) {
+			slog.Warn("Value stale", slog.String("value", logValue), slog.Time("lastSeen", seen.LastSeen))
+		}
+	}
+	lp.staleBefore = staleBefore
+	lp.staleValues.Store(stale)
+}
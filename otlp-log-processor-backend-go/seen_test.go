@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestDash0LogsProcessor_MarkSeen(t *testing.T) {
+	reader := metric.NewManualReader()
+	counter, err := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test").Int64Counter("com.dash0.homeexercise.values.new")
+	if err != nil {
+		t.Fatalf("Failed to create counter: %v", err)
+	}
+	originalCounter := newValuesCounter
+	newValuesCounter = counter
+	defer func() { newValuesCounter = originalCounter }()
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lp := &dash0LogsProcessor{}
+	lp.markSeen("checkout", start)
+	lp.markSeen("cart", start.Add(time.Second))
+	lp.markSeen("checkout", start.Add(2*time.Second))
+
+	expected := map[string]valueSeen{
+		"checkout": {FirstSeen: start, LastSeen: start.Add(2 * time.Second)},
+		"cart":     {FirstSeen: start.Add(time.Second), LastSeen: start.Add(time.Second)},
+	}
+	for value, seen := range expected {
+		if lp.seen[value] != seen {
+			t.Errorf("Expected %s to be seen %+v, got %+v", value, seen, lp.seen[value])
+		}
+	}
+
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &resourceMetrics); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+	var newValues int64
+	for _, sm := range resourceMetrics.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+				if dp.Attributes.Len() != 0 {
+					t.Errorf("Expected no attributes on the new values counter, got %v", dp.Attributes.ToSlice())
+				}
+				newValues += dp.Value
+			}
+		}
+	}
+	if newValues != 2 {
+		t.Errorf("Expected 2 new values, got %d", newValues)
+	}
+}
+
+func TestDash0LogsProcessor_CheckStale(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	seen := map[string]valueSeen{
+		"checkout": {FirstSeen: now.Add(-3 * time.Hour), LastSeen: now.Add(-time.Minute)},
+		"cart":     {FirstSeen: now.Add(-3 * time.Hour), LastSeen: now.Add(-2 * time.Hour)},
+		"payment":  {FirstSeen: now.Add(-3 * time.Hour), LastSeen: now.Add(-30 * time.Minute)},
+	}
+
+	tests := map[string]struct {
+		staleAfter    time.Duration
+		expectedStale int64
+	}{
+		"Hour": {
+			staleAfter:    time.Hour,
+			expectedStale: 1,
+		},
+		"TenMinutes": {
+			staleAfter:    10 * time.Minute,
+			expectedStale: 2,
+		},
+		"Disabled": {
+			staleAfter:    0,
+			expectedStale: 0,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			lp := &dash0LogsProcessor{seen: seen, staleAfter: test.staleAfter}
+			lp.checkStale(now)
+
+			if stale := lp.staleValues.Load(); stale != test.expectedStale {
+				t.Errorf("Expected %d stale values, got %d", test.expectedStale, stale)
+			}
+			stats := lp.stats(now)
+			var stale int64
+			for _, s := range stats.Seen {
+				if s.stale(stats.StaleBefore) {
+					stale++
+				}
+			}
+			if stale != test.expectedStale {
+				t.Errorf("Expected %d stale values in the stats, got %d", test.expectedStale, stale)
+			}
+		})
+	}
+}
@@ -7,6 +7,11 @@ import (
 	"log"
 	"log/slog"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"go.opentelemetry.io/contrib/bridges/otelslog"
@@ -16,14 +21,9 @@ import (
 	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
-)
-
-var (
-	listenAddr            = flag.String("listenAddr", "localhost:4317", "The listen address")
-	maxReceiveMessageSize = flag.Int("maxReceiveMessageSize", 16777216, "The max message size in bytes the server can receive")
-	attributeKey          = flag.String("attributeKey", "service.name", "The attributeKey to count")
-	durationWindow        = flag.Duration("duration", time.Second*10, "The duration between the output of the stats of the attributeKey")
-	bufferSize            = flag.Uint("bufferSize", 1000, "The size of the buffer for log ingestion")
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 )
 
 const name = "dash0.com/otlp-log-processor-backend"
@@ -33,15 +33,35 @@ var (
 	meter                       = otel.Meter(name)
 	logger                      = otelslog.NewLogger(name)
 	logsReceivedCounter         metric.Int64Counter
+	logRecordsReceivedCounter   metric.Int64Counter
 	resourceAttributeHitCounter metric.Int64Counter
 	logAttributeHitCounter      metric.Int64Counter
 	scopeAttributeHitCounter    metric.Int64Counter
+	throttledLogsCounter        metric.Int64Counter
+	filteredValuesCounter       metric.Int64Counter
+	filteredLogRecordsCounter   metric.Int64Counter
+	enqueueDurationHistogram    metric.Float64Histogram
+	intakeLengthGauge           metric.Int64ObservableGauge
+	intakeCapacityGauge         metric.Int64ObservableGauge
+	distinctValuesGauge         metric.Int64ObservableGauge
+	forwardedCounter            metric.Int64Counter
+	forwardFailedCounter        metric.Int64Counter
+	sampledCounter              metric.Int64Counter
+	anomaliesCounter            metric.Int64Counter
+	newValuesCounter            metric.Int64Counter
+	staleValuesGauge            metric.Int64ObservableGauge
 )
 
 func init() {
 	var err error
 	logsReceivedCounter, err = meter.Int64Counter("com.dash0.homeexercise.logs.received",
-		metric.WithDescription("The number of logs received by otlp-log-processor-backend"),
+		metric.WithDescription("The number of export requests received by otlp-log-processor-backend"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		panic(err)
+	}
+	logRecordsReceivedCounter, err = meter.Int64Counter("com.dash0.homeexercise.logs.records.received",
+		metric.WithDescription("The number of log records received by otlp-log-processor-backend"),
 		metric.WithUnit("{log}"))
 	if err != nil {
 		panic(err)
@@ -64,6 +84,97 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+	throttledLogsCounter, err = meter.Int64Counter("com.dash0.homeexercise.logs.throttled",
+		metric.WithDescription("The number of log records rejected by the per-client rate limit"),
+		metric.WithUnit("{log}"))
+	if err != nil {
+		panic(err)
+	}
+	filteredValuesCounter, err = meter.Int64Counter("com.dash0.homeexercise.values.filtered",
+		metric.WithDescription("The number of attribute values rejected by the minimum severity and the include and exclude filters, a log record may carry several"),
+		metric.WithUnit("{value}"))
+	if err != nil {
+		panic(err)
+	}
+	filteredLogRecordsCounter, err = meter.Int64Counter("com.dash0.homeexercise.logs.records.filtered",
+		metric.WithDescription("The number of log records with an attribute value rejected by the minimum severity or the include and exclude filters"),
+		metric.WithUnit("{log}"))
+	if err != nil {
+		panic(err)
+	}
+	enqueueDurationHistogram, err = meter.Float64Histogram("com.dash0.homeexercise.enqueue.duration",
+		metric.WithDescription("The time an export spends persisting and handing its values to the processor"),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5))
+	if err != nil {
+		panic(err)
+	}
+	intakeLengthGauge, err = meter.Int64ObservableGauge("com.dash0.homeexercise.intake.length",
+		metric.WithDescription("The number of values waiting in the intake channel of the processor"),
+		metric.WithUnit("{value}"))
+	if err != nil {
+		panic(err)
+	}
+	intakeCapacityGauge, err = meter.Int64ObservableGauge("com.dash0.homeexercise.intake.capacity",
+		metric.WithDescription("The capacity of the intake channel of the processor"),
+		metric.WithUnit("{value}"))
+	if err != nil {
+		panic(err)
+	}
+	distinctValuesGauge, err = meter.Int64ObservableGauge("com.dash0.homeexercise.values.distinct",
+		metric.WithDescription("The number of distinct attribute values counted since the start"),
+		metric.WithUnit("{value}"))
+	if err != nil {
+		panic(err)
+	}
+	forwardedCounter, err = meter.Int64Counter("com.dash0.homeexercise.logs.forwarded",
+		metric.WithDescription("The number of log records forwarded to the downstream OTLP endpoint"),
+		metric.WithUnit("{log}"))
+	if err != nil {
+		panic(err)
+	}
+	forwardFailedCounter, err = meter.Int64Counter("com.dash0.homeexercise.logs.forward.failed",
+		metric.WithDescription("The number of log records which could not be forwarded to the downstream OTLP endpoint"),
+		metric.WithUnit("{log}"))
+	if err != nil {
+		panic(err)
+	}
+	sampledCounter, err = meter.Int64Counter("com.dash0.homeexercise.logs.sampled",
+		metric.WithDescription("The number of values of log records kept or dropped by the adaptive sampling of the forwarded logs"),
+		metric.WithUnit("{value}"))
+	if err != nil {
+		panic(err)
+	}
+	newValuesCounter, err = meter.Int64Counter("com.dash0.homeexercise.values.new",
+		metric.WithDescription("The number of values which were counted for the first time"),
+		metric.WithUnit("{value}"))
+	if err != nil {
+		panic(err)
+	}
+	staleValuesGauge, err = meter.Int64ObservableGauge("com.dash0.homeexercise.values.stale",
+		metric.WithDescription("The number of values which were not counted for the stale duration at the last window close"),
+		metric.WithUnit("{value}"))
+	if err != nil {
+		panic(err)
+	}
+	anomaliesCounter, err = meter.Int64Counter("com.dash0.homeexercise.values.anomalies",
+		metric.WithDescription("The number of closed windows in which the count of a value deviated from its baseline"),
+		metric.WithUnit("{anomaly}"))
+	if err != nil {
+		panic(err)
+	}
+}
+
+// registerServerMetrics observes the intake channel and the processor of server.
+// The returned registration must be unregistered once the server is stopped.
+func registerServerMetrics(m metric.Meter, server *dash0LogsServiceServer) (metric.Registration, error) {
+	return m.RegisterCallback(func(_ context.Context, observer metric.Observer) error {
+		observer.ObserveInt64(intakeLengthGauge, int64(len(server.logExport)))
+		observer.ObserveInt64(intakeCapacityGauge, int64(cap(server.logExport)))
+		observer.ObserveInt64(distinctValuesGauge, server.processor.distinctValues.Load())
+		observer.ObserveInt64(staleValuesGauge, server.processor.staleValues.Load())
+		return nil
+	}, intakeLengthGauge, intakeCapacityGauge, distinctValuesGauge, staleValuesGauge)
 }
 
 func main() {
@@ -73,6 +184,14 @@ func main() {
 }
 
 func run() (err error) {
+	cfg, err := loadConfig(os.Args[1:], flag.ExitOnError)
+	if err != nil {
+		return err
+	}
+	if cfg.printConfig {
+		return cfg.writeTo(os.Stdout)
+	}
+
 	slog.SetDefault(logger)
 	logger.Info("Starting application")
 
@@ -87,22 +206,219 @@ func run() (err error) {
 		err = errors.Join(err, otelShutdown(context.Background()))
 	}()
 
-	flag.Parse()
+	var current atomic.Pointer[config]
+	current.Store(cfg)
 
-	slog.Debug("Starting listener", slog.String("listenAddr", *listenAddr))
-	listener, err := net.Listen("tcp", *listenAddr)
+	slog.Debug("Starting listener", slog.String("listenAddr", cfg.Listen.Addr))
+	listener, err := net.Listen("tcp", cfg.Listen.Addr)
 	if err != nil {
 		return err
 	}
 
 	grpcServer := grpc.NewServer(
 		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-		grpc.MaxRecvMsgSize(*maxReceiveMessageSize),
+		grpc.MaxRecvMsgSize(cfg.Listen.MaxReceiveMessageSize),
 		grpc.Creds(insecure.NewCredentials()),
 	)
-	collogspb.RegisterLogsServiceServer(grpcServer, newServer(*listenAddr, *attributeKey, *durationWindow, *bufferSize))
+	opts, closeOpts, err := newServerOptions(cfg)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Join(err, closeOpts())
+	}()
+	logsServer := newServer(cfg.Listen.Addr, cfg.Attribute.Key, cfg.Window.Duration, cfg.Intake.BufferSize, opts...)
+	collogspb.RegisterLogsServiceServer(grpcServer, logsServer)
+	metricsRegistration, err := registerServerMetrics(meter, logsServer)
+	if err != nil {
+		return err
+	}
+	defer metricsRegistration.Unregister()
+
+	healthServer := health.NewServer()
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	if cfg.Listen.Reflection {
+		reflection.Register(grpcServer)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The health monitor is restarted whenever the config reload changes its settings.
+	cancelMonitor := startIntakeHealthMonitor(ctx, healthServer, logsServer, cfg.Intake.FullDuration)
+
+	var adminServer *http.Server
+	if cfg.Admin.Addr != "" {
+		slog.Debug("Starting admin listener", slog.String("adminAddr", cfg.Admin.Addr))
+		adminListener, err := net.Listen("tcp", cfg.Admin.Addr)
+		if err != nil {
+			return err
+		}
+		adminServer = &http.Server{Handler: newAdminHandler(logsServer.processor)}
+		go func() {
+			if err := adminServer.Serve(adminListener); !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("Admin HTTP server failed", slog.Any("error", err))
+			}
+		}()
+	}
 
-	slog.Debug("Starting gRPC server", "attributeKey", *attributeKey, "durationWindow", durationWindow)
+	reloader := newConfigReloader(os.Args[1:], cfg, func(next *config) error {
+		if err := logsServer.applyConfig(ctx, next); err != nil {
+			return err
+		}
+		if next.Intake.FullDuration != current.Load().Intake.FullDuration {
+			cancelMonitor()
+			cancelMonitor = startIntakeHealthMonitor(ctx, healthServer, logsServer, next.Intake.FullDuration)
+		}
+		current.Store(next)
+		slog.Info("Applied configuration", "attributeKey", next.Attribute.Key, "durationWindow", next.Window.Duration)
+		return nil
+	})
+	go reloader.run(ctx)
+
+	go func() {
+		<-ctx.Done()
+		drainDelay := current.Load().Shutdown.DrainDelay
+		slog.Info("Draining gRPC server", slog.Duration("drainDelay", drainDelay))
+		healthServer.Shutdown()
+		time.Sleep(drainDelay)
+		grpcServer.GracefulStop()
+		if adminServer != nil {
+			_ = adminServer.Close()
+		}
+	}()
+
+	slog.Debug("Starting gRPC server", "attributeKey", cfg.Attribute.Key, "durationWindow", cfg.Window.Duration)
+
+	err = grpcServer.Serve(listener)
+	logsServer.processor.Stop()
+	return err
+}
+
+// newServerOptions translates the configuration into options of the dash0LogsServiceServer.
+// The returned close function releases the resources of the options once the processor is stopped.
+func newServerOptions(cfg *config) (opts []serverOption, closeOpts func() error, err error) {
+	var closers []func() error
+	closeOpts = func() error {
+		var errs []error
+		for i := len(closers) - 1; i >= 0; i-- {
+			errs = append(errs, closers[i]())
+		}
+		return errors.Join(errs...)
+	}
+
+	aliases := newKeyAliases(cfg.Attribute.SemconvAliases, cfg.aliasTable)
+	opts = append(opts, withAliases(aliases))
+
+	filter, err := newValueFilter(cfg.Filter.Include, cfg.Filter.Exclude, aliases)
+	if err != nil {
+		return nil, closeOpts, err
+	}
+	opts = append(opts, withFilter(filter))
+
+	transforms, err := newValueTransforms(cfg.Transforms)
+	if err != nil {
+		return nil, closeOpts, err
+	}
+	opts = append(opts, withTransforms(transforms))
+
+	minSeverity, err := parseSeverityRange(cfg.Severity.Min)
+	if err != nil {
+		return nil, closeOpts, err
+	}
+	opts = append(opts, withSeverity(cfg.Severity.Breakdown, minSeverity))
+
+	keyPattern, err := newKeyPattern(cfg.Attribute.Key, cfg.Attribute.KeyMatch)
+	if err != nil {
+		return nil, closeOpts, err
+	}
+	if keyPattern != nil {
+		opts = append(opts, withKeyPattern(keyPattern, cfg.Attribute.CountMatchedKey))
+	}
+
+	bodyPattern, err := newBodyPattern(cfg.Attribute.BodyPattern)
+	if err != nil {
+		return nil, closeOpts, err
+	}
+	opts = append(opts, withBodyPattern(bodyPattern))
+	if cfg.Attribute.ExplodeArrays {
+		opts = append(opts, withExplodeArrays())
+	}
+	renderer, err := newValueRenderer(cfg.Attribute.Rendering, cfg.Attribute.Typed)
+	if err != nil {
+		return nil, closeOpts, err
+	}
+	opts = append(opts, withRenderer(renderer))
+
+	if cfg.RateLimit.RecordsPerSecond > 0 {
+		opts = append(opts, withRateLimit(cfg.RateLimit.RecordsPerSecond, cfg.RateLimit.Burst, cfg.RateLimit.TenantHeader))
+	}
+
+	var walStart uint64
+	if cfg.Snapshot.File != "" {
+		restored, err := readSnapshot(cfg.Snapshot.File)
+		if err != nil {
+			return nil, closeOpts, err
+		}
+		if restored != nil {
+			slog.Info("Restoring snapshot", slog.String("path", cfg.Snapshot.File), slog.Time("savedAt", restored.SavedAt))
+			walStart = restored.WALSequence
+		}
+		opts = append(opts, withSnapshots(cfg.Snapshot.File, cfg.Snapshot.Interval, restored))
+	}
+
+	if cfg.WAL.Dir != "" {
+		wal, err := openWAL(cfg.WAL.Dir, cfg.WAL.Fsync)
+		if err != nil {
+			return nil, closeOpts, err
+		}
+		closers = append(closers, wal.Close)
+
+		// Values up to the snapshot are already part of the restored counts, even if the checkpoint lags behind.
+		walStart = min(max(walStart, wal.checkpointSeq), wal.nextSeq)
+		unprocessed, err := wal.readFrom(walStart)
+		if err != nil {
+			return nil, closeOpts, err
+		}
+		if len(unprocessed) > 0 {
+			slog.Info("Replaying write-ahead log", slog.String("walDir", cfg.WAL.Dir), slog.Int("values", len(unprocessed)))
+		}
+		opts = append(opts, withWAL(wal, walStart, unprocessed, cfg.WAL.CheckpointInterval))
+	}
+
+	if cfg.Forward.Endpoint != "" {
+		client, err := newForwardClient(cfg.Forward)
+		if err != nil {
+			return nil, closeOpts, err
+		}
+		forwarder := newLogsForwarder(client, cfg.Forward)
+		closers = append(closers, func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), cfg.Forward.Timeout)
+			defer cancel()
+			return forwarder.shutdown(ctx)
+		})
+		opts = append(opts, withForwarder(forwarder))
+		sampling := samplingSettings{
+			target:               cfg.Forward.Sampling.TargetPerWindow,
+			probabilityAttribute: cfg.Forward.Sampling.ProbabilityAttribute,
+		}
+		if enricher := newLogEnricher(cfg.Forward.CountAttribute, sampling); enricher != nil {
+			opts = append(opts, withEnricher(enricher))
+		}
+	}
+
+	opts = append(opts, withStaleAfter(cfg.Values.StaleAfter))
+	if anomalies := newAnomalyDetector(cfg.Anomaly.settings()); anomalies != nil {
+		opts = append(opts, withAnomalyDetection(anomalies))
+	}
+
+	alerts, err := newAlertEvaluator(cfg.Alerts.Rules)
+	if err != nil {
+		return nil, closeOpts, err
+	}
+	notifier := newAlertNotifier(cfg.Alerts.Webhook.URL, cfg.Alerts.Webhook.Timeout)
+	closers = append(closers, notifier.Close)
+	opts = append(opts, withAlerts(alerts, notifier))
 
-	return grpcServer.Serve(listener)
+	return opts, closeOpts, nil
 }
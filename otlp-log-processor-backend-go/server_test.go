@@ -7,6 +7,8 @@ import (
 	"testing"
 	"time"
 
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
 	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
 	"google.golang.org/grpc"
@@ -97,3 +99,75 @@ func server() (collogspb.LogsServiceClient, func()) {
 
 	return client, closer
 }
+
+func TestRegisterServerMetrics(t *testing.T) {
+	ctx := context.Background()
+
+	reader := metric.NewManualReader()
+	provider := metric.NewMeterProvider(metric.WithReader(reader))
+	testMeter := provider.Meter("test")
+
+	originalLength, originalCapacity, originalDistinct, originalStale := intakeLengthGauge, intakeCapacityGauge, distinctValuesGauge, staleValuesGauge
+	defer func() {
+		intakeLengthGauge, intakeCapacityGauge, distinctValuesGauge, staleValuesGauge = originalLength, originalCapacity, originalDistinct, originalStale
+	}()
+	var err error
+	if intakeLengthGauge, err = testMeter.Int64ObservableGauge("com.dash0.homeexercise.intake.length"); err != nil {
+		t.Fatalf("Failed to create gauge: %v", err)
+	}
+	if intakeCapacityGauge, err = testMeter.Int64ObservableGauge("com.dash0.homeexercise.intake.capacity"); err != nil {
+		t.Fatalf("Failed to create gauge: %v", err)
+	}
+	if distinctValuesGauge, err = testMeter.Int64ObservableGauge("com.dash0.homeexercise.values.distinct"); err != nil {
+		t.Fatalf("Failed to create gauge: %v", err)
+	}
+	if staleValuesGauge, err = testMeter.Int64ObservableGauge("com.dash0.homeexercise.values.stale"); err != nil {
+		t.Fatalf("Failed to create gauge: %v", err)
+	}
+
+	logsServer := newServer("localhost:4317", "service.name", time.Hour, 10)
+	defer logsServer.processor.Stop()
+	registration, err := registerServerMetrics(testMeter, logsServer)
+	if err != nil {
+		t.Fatalf("registerServerMetrics failed: %v", err)
+	}
+	defer registration.Unregister()
+
+	for _, value := range []string{"a", "b", "a"} {
+		logsServer.logExport <- value
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(logsServer.logExport) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	// The last value may have left the channel without being counted yet.
+	for logsServer.processor.distinctValues.Load() < 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	var resourceMetrics metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &resourceMetrics); err != nil {
+		t.Fatalf("Failed to collect metrics: %v", err)
+	}
+
+	observed := make(map[string]int64)
+	for _, sm := range resourceMetrics.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			for _, dp := range m.Data.(metricdata.Gauge[int64]).DataPoints {
+				observed[m.Name] = dp.Value
+			}
+		}
+	}
+
+	expected := map[string]int64{
+		"com.dash0.homeexercise.intake.length":   0,
+		"com.dash0.homeexercise.intake.capacity": 10,
+		"com.dash0.homeexercise.values.distinct": 2,
+		"com.dash0.homeexercise.values.stale":    0,
+	}
+	for name, value := range expected {
+		if got, ok := observed[name]; !ok || got != value {
+			t.Errorf("Expected %s %d, got %d", name, value, got)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// severityRange is a severity normalized to the ranges of the OTLP severity numbers, e.g. 17 to 20 are ERROR.
+// The ranges are ordered, so a minimum severity is a simple comparison.
+type severityRange int
+
+const (
+	severityUnspecified severityRange = iota
+	severityTrace
+	severityDebug
+	severityInfo
+	severityWarn
+	severityError
+	severityFatal
+)
+
+var severityRangeNames = []string{"UNSPECIFIED", "TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// severityTextAliases maps severity texts of common logging libraries which do not start with a range name.
+var severityTextAliases = map[string]severityRange{
+	"WARNING":     severityWarn,
+	"ERR":         severityError,
+	"CRITICAL":    severityFatal,
+	"CRIT":        severityFatal,
+	"PANIC":       severityFatal,
+	"EMERGENCY":   severityFatal,
+	"ALERT":       severityFatal,
+	"NOTICE":      severityInfo,
+	"INFORMATION": severityInfo,
+}
+
+func (s severityRange) String() string {
+	if s < severityUnspecified || int(s) >= len(severityRangeNames) {
+		return severityRangeNames[severityUnspecified]
+	}
+	return severityRangeNames[s]
+}
+
+// parseSeverityRange parses a range name as used in the configuration. The empty name is UNSPECIFIED.
+func parseSeverityRange(name string) (severityRange, error) {
+	if name == "" {
+		return severityUnspecified, nil
+	}
+	for i, rangeName := range severityRangeNames {
+		if strings.EqualFold(name, rangeName) {
+			return severityRange(i), nil
+		}
+	}
+	return severityUnspecified, fmt.Errorf("unknown severity %q, expected one of %s", name, strings.Join(severityRangeNames[1:], ", "))
+}
+
+// recordSeverity returns the severity range of the log record.
+// Without a severity number, the severity text is mapped, e.g. "warning" or "Error".
+func recordSeverity(logRecord *otellogs.LogRecord) severityRange {
+	if number := logRecord.GetSeverityNumber(); number > otellogs.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED {
+		return min(severityRange((number-1)/4)+severityTrace, severityFatal)
+	}
+
+	text := strings.ToUpper(strings.TrimSpace(logRecord.GetSeverityText()))
+	if text == "" {
+		return severityUnspecified
+	}
+	if s, ok := severityTextAliases[text]; ok {
+		return s
+	}
+	// Severity texts like WARN2 or ERROR3 name a range followed by a digit.
+	for i := len(severityRangeNames) - 1; i > 0; i-- {
+		if strings.HasPrefix(text, severityRangeNames[i]) {
+			return severityRange(i)
+		}
+	}
+	return severityUnspecified
+}
+
+// severitySettings configure the severity-aware counting.
+type severitySettings struct {
+	breakdown bool
+	min       severityRange
+}
+
+// enabled reports whether the values are counted per log record, which is required to know their severity.
+func (s severitySettings) enabled() bool {
+	return s.breakdown || s.min > severityUnspecified
+}
+
+// withSeverityRange appends the severity range to a value, e.g. "checkout [ERROR]".
+func withSeverityRange(value string, severity severityRange) string {
+	return value + " [" + severity.String() + "]"
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+	otelresource "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+func TestRecordSeverity(t *testing.T) {
+	tests := map[string]struct {
+		logRecord *otellogs.LogRecord
+		expected  severityRange
+	}{
+		"Unspecified": {
+			logRecord: &otellogs.LogRecord{},
+			expected:  severityUnspecified,
+		},
+		"Trace": {
+			logRecord: &otellogs.LogRecord{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_TRACE},
+			expected:  severityTrace,
+		},
+		"Info4": {
+			logRecord: &otellogs.LogRecord{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_INFO4},
+			expected:  severityInfo,
+		},
+		"Warn": {
+			logRecord: &otellogs.LogRecord{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_WARN},
+			expected:  severityWarn,
+		},
+		"Error2": {
+			logRecord: &otellogs.LogRecord{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_ERROR2},
+			expected:  severityError,
+		},
+		"Fatal4": {
+			logRecord: &otellogs.LogRecord{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_FATAL4},
+			expected:  severityFatal,
+		},
+		"OutOfRange": {
+			logRecord: &otellogs.LogRecord{SeverityNumber: 30},
+			expected:  severityFatal,
+		},
+		"NumberTakesPrecedence": {
+			logRecord: &otellogs.LogRecord{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_DEBUG, SeverityText: "ERROR"},
+			expected:  severityDebug,
+		},
+		"TextWarning": {
+			logRecord: &otellogs.LogRecord{SeverityText: "warning"},
+			expected:  severityWarn,
+		},
+		"TextError3": {
+			logRecord: &otellogs.LogRecord{SeverityText: "Error3"},
+			expected:  severityError,
+		},
+		"TextCritical": {
+			logRecord: &otellogs.LogRecord{SeverityText: "CRITICAL"},
+			expected:  severityFatal,
+		},
+		"TextUnknown": {
+			logRecord: &otellogs.LogRecord{SeverityText: "verbose"},
+			expected:  severityUnspecified,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if got := recordSeverity(test.logRecord); got != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseSeverityRange(t *testing.T) {
+	tests := map[string]struct {
+		name          string
+		expected      severityRange
+		expectedError bool
+	}{
+		"Empty":     {name: "", expected: severityUnspecified},
+		"Upper":     {name: "WARN", expected: severityWarn},
+		"Lower":     {name: "error", expected: severityError},
+		"Unknown":   {name: "warning", expectedError: true},
+		"NotARange": {name: "5", expectedError: true},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			got, err := parseSeverityRange(test.name)
+			if (err != nil) != test.expectedError {
+				t.Fatalf("Expected error %t, got %v", test.expectedError, err)
+			}
+			if got != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+}
+
+func createSeverityRequest() *collogspb.ExportLogsServiceRequest {
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{
+			{
+				Resource: &otelresource.Resource{
+					Attributes: []*otelcommon.KeyValue{stringKeyValue("service.name", "checkout")},
+				},
+				ScopeLogs: []*otellogs.ScopeLogs{
+					{
+						LogRecords: []*otellogs.LogRecord{
+							{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_INFO},
+							{SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_ERROR},
+							{SeverityText: "WARNING"},
+							{
+								SeverityNumber: otellogs.SeverityNumber_SEVERITY_NUMBER_ERROR,
+								Attributes:     []*otelcommon.KeyValue{stringKeyValue("service.name", "payment")},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValueExtractor_Severity(t *testing.T) {
+	tests := map[string]struct {
+		severity         severitySettings
+		expected         []string
+		expectedFiltered int
+	}{
+		"PerLevel": {
+			severity: severitySettings{},
+			expected: []string{"checkout", "payment"},
+		},
+		"Breakdown": {
+			severity: severitySettings{breakdown: true},
+			expected: []string{"checkout [INFO]", "checkout [ERROR]", "checkout [WARN]", "payment [ERROR]"},
+		},
+		"MinSeverity": {
+			severity:         severitySettings{min: severityWarn},
+			expected:         []string{"checkout", "checkout", "payment"},
+			expectedFiltered: 1,
+		},
+		"BreakdownAndMinSeverity": {
+			severity:         severitySettings{breakdown: true, min: severityError},
+			expected:         []string{"checkout [ERROR]", "payment [ERROR]"},
+			expectedFiltered: 2,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			extractor := valueExtractor{attributeKey: "service.name", severity: test.severity}
+			values, hits := extractor.extract(context.Background(), createSeverityRequest())
+			if !slices.Equal(values, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, values)
+			}
+			if hits.filtered != test.expectedFiltered {
+				t.Errorf("Expected %d filtered, got %d", test.expectedFiltered, hits.filtered)
+			}
+		})
+	}
+}
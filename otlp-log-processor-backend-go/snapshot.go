@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotFormatVersion is increased whenever the snapshot format changes incompatibly.
+const snapshotFormatVersion = 1
+
+// processorSnapshot is the persisted state of the dash0LogsProcessor.
+type processorSnapshot struct {
+	Version        int                  `json:"version"`
+	SavedAt        time.Time            `json:"savedAt"`
+	DurationWindow string               `json:"durationWindow"`
+	WindowStart    time.Time            `json:"windowStart"`
+	WindowCounts   map[string]uint64    `json:"windowCounts"`
+	LastWindow     *snapshotWindow      `json:"lastWindow,omitempty"`
+	TotalCounts    map[string]uint64    `json:"totalCounts"`
+	Seen           map[string]valueSeen `json:"seen,omitempty"`
+	WALSequence    uint64               `json:"walSequence,omitempty"`
+}
+
+type snapshotWindow struct {
+	Start  time.Time         `json:"start"`
+	End    time.Time         `json:"end"`
+	Counts map[string]uint64 `json:"counts"`
+}
+
+// writeSnapshot replaces the snapshot at path atomically.
+func writeSnapshot(path string, snapshot *processorSnapshot) error {
+	return writeFileAtomic(path, func(file io.Writer) error {
+		if err := json.NewEncoder(file).Encode(snapshot); err != nil {
+			return fmt.Errorf("encoding snapshot: %w", err)
+		}
+		return nil
+	})
+}
+
+// writeFileAtomic replaces the file at path with the content written by write.
+// The content is written to a temporary file in the same directory which is synced and renamed afterward,
+// so a crash never leaves a partially written file behind.
+func writeFileAtomic(path string, write func(file io.Writer) error) (err error) {
+	dir := filepath.Dir(path)
+	file, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+		}
+	}()
+
+	if err = write(file); err != nil {
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+	if err = os.Rename(file.Name(), path); err != nil {
+		return fmt.Errorf("renaming temporary file: %w", err)
+	}
+
+	return syncDir(dir)
+}
+
+// syncDir makes a rename within dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// readSnapshot reads the snapshot at path. A missing snapshot is not an error and returns nil.
+func readSnapshot(path string) (*processorSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot: %w", err)
+	}
+
+	var snapshot processorSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("decoding snapshot %s: %w", path, err)
+	}
+	if snapshot.Version < 1 || snapshot.Version > snapshotFormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot format version %d in %s", snapshot.Version, path)
+	}
+
+	return &snapshot, nil
+}
+
+func (lp *dash0LogsProcessor) snapshot(now time.Time) *processorSnapshot {
+	snapshot := &processorSnapshot{
+		Version:        snapshotFormatVersion,
+		SavedAt:        now,
+		DurationWindow: lp.durationWindow.String(),
+		WindowStart:    lp.windowStart,
+		WindowCounts:   lp.windowStats,
+		TotalCounts:    lp.logStats,
+		Seen:           lp.seen,
+		WALSequence:    lp.walSeq,
+	}
+	if !lp.lastWindow.Start.IsZero() {
+		snapshot.LastWindow = &snapshotWindow{
+			Start:  lp.lastWindow.Start,
+			End:    lp.lastWindow.End,
+			Counts: lp.lastWindow.Counts,
+		}
+	}
+	return snapshot
+}
+
+// restore loads the counts of a snapshot into the processor before the processing starts.
+// The window of the snapshot is continued if it was still open at now, otherwise it becomes the last completed window.
+func (lp *dash0LogsProcessor) restore(snapshot *processorSnapshot, now time.Time) {
+	lp.logStats = snapshot.TotalCounts
+	if lp.logStats == nil {
+		lp.logStats = make(map[string]uint64)
+	}
+	lp.distinctValues.Store(int64(len(lp.logStats)))
+	// Values without seen times, e.g. of older snapshots, were last seen when the snapshot was saved.
+	lp.seen = snapshot.Seen
+	if lp.seen == nil {
+		lp.seen = make(map[string]valueSeen, len(lp.logStats))
+	}
+	for logValue := range lp.logStats {
+		if _, ok := lp.seen[logValue]; !ok {
+			lp.seen[logValue] = valueSeen{FirstSeen: snapshot.SavedAt, LastSeen: snapshot.SavedAt}
+		}
+	}
+	if snapshot.LastWindow != nil {
+		lp.lastWindow = windowStats{
+			Start:  snapshot.LastWindow.Start,
+			End:    snapshot.LastWindow.End,
+			Counts: snapshot.LastWindow.Counts,
+		}
+	}
+
+	durationWindow, err := time.ParseDuration(snapshot.DurationWindow)
+	if err == nil && durationWindow == lp.durationWindow && now.Before(snapshot.WindowStart.Add(durationWindow)) {
+		lp.windowStart = snapshot.WindowStart
+		lp.windowStats = snapshot.WindowCounts
+	} else if len(snapshot.WindowCounts) > 0 {
+		lp.lastWindow = windowStats{
+			Start:  snapshot.WindowStart,
+			End:    snapshot.SavedAt,
+			Counts: snapshot.WindowCounts,
+		}
+	}
+	if lp.windowStats == nil {
+		lp.windowStats = make(map[string]uint64)
+	}
+	lastCounts := lp.lastWindow.Counts
+	lp.lastWindowCounts.Store(&lastCounts)
+}
+
+func (lp *dash0LogsProcessor) saveSnapshot(now time.Time) error {
+	return writeSnapshot(lp.snapshotPath, lp.snapshot(now))
+}
+
+// saveSnapshotAndCheckpoint writes a snapshot and checkpoints the write-ahead log up to it.
+// If the snapshot fails, the counted values only exist in memory, so the log keeps them for the replay after a crash.
+func (lp *dash0LogsProcessor) saveSnapshotAndCheckpoint(now time.Time) {
+	if err := lp.saveSnapshot(now); err != nil {
+		slog.Error("Failed to write snapshot, skipping the write-ahead log checkpoint", slog.String("path", lp.snapshotPath), slog.Any("error", err))
+		return
+	}
+	lp.checkpoint()
+}
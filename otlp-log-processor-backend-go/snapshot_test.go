@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteSnapshot_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	snapshot := &processorSnapshot{
+		Version:        snapshotFormatVersion,
+		SavedAt:        start.Add(5 * time.Second),
+		DurationWindow: "10s",
+		WindowStart:    start,
+		WindowCounts:   map[string]uint64{"checkout": 2},
+		LastWindow: &snapshotWindow{
+			Start:  start.Add(-10 * time.Second),
+			End:    start,
+			Counts: map[string]uint64{"checkout": 5},
+		},
+		TotalCounts: map[string]uint64{"checkout": 7},
+	}
+
+	if err := writeSnapshot(path, snapshot); err != nil {
+		t.Fatalf("writeSnapshot failed: %v", err)
+	}
+	// Overwriting an existing snapshot must succeed as well.
+	snapshot.TotalCounts["checkout"] = 8
+	if err := writeSnapshot(path, snapshot); err != nil {
+		t.Fatalf("writeSnapshot failed: %v", err)
+	}
+
+	restored, err := readSnapshot(path)
+	if err != nil {
+		t.Fatalf("readSnapshot failed: %v", err)
+	}
+	if restored.TotalCounts["checkout"] != 8 || restored.WindowCounts["checkout"] != 2 || restored.LastWindow.Counts["checkout"] != 5 {
+		t.Errorf("Expected restored counts to match, got %+v", restored)
+	}
+	if !restored.WindowStart.Equal(start) || restored.DurationWindow != "10s" {
+		t.Errorf("Expected restored window metadata to match, got %+v", restored)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the snapshot file to remain, got %d entries", len(entries))
+	}
+}
+
+func TestReadSnapshot(t *testing.T) {
+	tests := map[string]struct {
+		content       string
+		expectedError string
+	}{
+		"Valid": {
+			content: `{"version":1,"durationWindow":"10s","totalCounts":{"checkout":1}}`,
+		},
+		"UnsupportedVersion": {
+			content:       `{"version":2,"totalCounts":{}}`,
+			expectedError: "unsupported snapshot format version 2",
+		},
+		"MissingVersion": {
+			content:       `{"totalCounts":{}}`,
+			expectedError: "unsupported snapshot format version 0",
+		},
+		"Corrupt": {
+			content:       `{"version":1,`,
+			expectedError: "decoding snapshot",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "snapshot.json")
+			if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+				t.Fatalf("WriteFile failed: %v", err)
+			}
+
+			_, err := readSnapshot(path)
+			if test.expectedError == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if test.expectedError != "" && (err == nil || !strings.Contains(err.Error(), test.expectedError)) {
+				t.Errorf("Expected error containing '%s', got %v", test.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestReadSnapshot_Missing(t *testing.T) {
+	snapshot, err := readSnapshot(filepath.Join(t.TempDir(), "snapshot.json"))
+	if err != nil || snapshot != nil {
+		t.Errorf("Expected no snapshot and no error, got %v, %v", snapshot, err)
+	}
+}
+
+func TestDash0LogsProcessor_Restore(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := &processorSnapshot{
+		Version:        snapshotFormatVersion,
+		SavedAt:        start.Add(5 * time.Second),
+		DurationWindow: "10s",
+		WindowStart:    start,
+		WindowCounts:   map[string]uint64{"checkout": 2},
+		TotalCounts:    map[string]uint64{"checkout": 7},
+	}
+
+	tests := map[string]struct {
+		durationWindow      time.Duration
+		now                 time.Time
+		expectedWindowStart time.Time
+		expectedCurrent     uint64
+		expectedLast        uint64
+	}{
+		"WindowStillOpen": {
+			durationWindow:      10 * time.Second,
+			now:                 start.Add(8 * time.Second),
+			expectedWindowStart: start,
+			expectedCurrent:     2,
+			expectedLast:        0,
+		},
+		"WindowExpired": {
+			durationWindow:  10 * time.Second,
+			now:             start.Add(time.Minute),
+			expectedCurrent: 0,
+			expectedLast:    2,
+		},
+		"DurationWindowChanged": {
+			durationWindow:  time.Minute,
+			now:             start.Add(8 * time.Second),
+			expectedCurrent: 0,
+			expectedLast:    2,
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			processor := &dash0LogsProcessor{durationWindow: test.durationWindow}
+			processor.restore(snapshot, test.now)
+
+			if processor.logStats["checkout"] != 7 {
+				t.Errorf("Expected total count of 7, got %d", processor.logStats["checkout"])
+			}
+			if processor.windowStats["checkout"] != test.expectedCurrent {
+				t.Errorf("Expected current window count of %d, got %d", test.expectedCurrent, processor.windowStats["checkout"])
+			}
+			if processor.lastWindow.Counts["checkout"] != test.expectedLast {
+				t.Errorf("Expected last window count of %d, got %d", test.expectedLast, processor.lastWindow.Counts["checkout"])
+			}
+			if !processor.windowStart.Equal(test.expectedWindowStart) {
+				t.Errorf("Expected window start %s, got %s", test.expectedWindowStart, processor.windowStart)
+			}
+			if seen := (valueSeen{FirstSeen: snapshot.SavedAt, LastSeen: snapshot.SavedAt}); processor.seen["checkout"] != seen {
+				t.Errorf("Expected checkout to be seen when the snapshot was saved, got %+v", processor.seen["checkout"])
+			}
+		})
+	}
+}
+
+func TestDash0LogsProcessor_StopWritesSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	logIntake := make(chan string, 10)
+	processor := &dash0LogsProcessor{
+		logStats:         make(map[string]uint64),
+		logIntake:        logIntake,
+		durationWindow:   time.Hour,
+		snapshotPath:     path,
+		snapshotInterval: time.Hour,
+		shutdown:         make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+
+	logIntake <- "checkout"
+	logIntake <- "checkout"
+	go processor.StartLogProcessing()
+	processor.Stop()
+
+	snapshot, err := readSnapshot(path)
+	if err != nil || snapshot == nil {
+		t.Fatalf("Expected a snapshot to be written, got %v", err)
+	}
+	if snapshot.TotalCounts["checkout"] != 2 {
+		t.Errorf("Expected buffered values to be counted before the final snapshot, got %v", snapshot.TotalCounts)
+	}
+}
+
+func TestDash0LogsProcessor_FailedSnapshotSkipsCheckpoint(t *testing.T) {
+	wal := openTestWAL(t, t.TempDir())
+	defer wal.Close()
+	if err := wal.append([]string{"checkout", "cart"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+
+	processor := &dash0LogsProcessor{
+		logStats:     map[string]uint64{"checkout": 1, "cart": 1},
+		snapshotPath: filepath.Join(t.TempDir(), "missing", "snapshot.json"),
+		wal:          wal,
+		walSeq:       2,
+	}
+	processor.saveSnapshotAndCheckpoint(time.Now())
+
+	if wal.checkpointSeq != 0 {
+		t.Errorf("Expected no checkpoint after a failed snapshot, got %d", wal.checkpointSeq)
+	}
+
+	processor.snapshotPath = filepath.Join(t.TempDir(), "snapshot.json")
+	processor.saveSnapshotAndCheckpoint(time.Now())
+
+	if wal.checkpointSeq != 2 {
+		t.Errorf("Expected a checkpoint at 2 after a written snapshot, got %d", wal.checkpointSeq)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"unicode/utf8"
+)
+
+// invalidAddress replaces the values which the CIDR transform cannot parse as an IP address.
+const invalidAddress = "invalid"
+
+// transformRule changes the values of an attribute key before they are counted, so sensitive values
+// never reach the counts, the snapshots, the write-ahead log or the output. Exactly one transform must be set.
+// Key is the attribute key, or the matched key for glob and regex attribute keys.
+type transformRule struct {
+	Key      string         `yaml:"key"`
+	Hash     *hashTransform `yaml:"hash,omitempty"`
+	Truncate int            `yaml:"truncate,omitempty"`
+	Mask     *maskTransform `yaml:"mask,omitempty"`
+	CIDR     *cidrTransform `yaml:"cidr,omitempty"`
+}
+
+// hashTransform replaces the value by the hex encoded SHA-256 hash of the salt followed by the value.
+type hashTransform struct {
+	Salt string `yaml:"salt"`
+}
+
+// redactedSalt replaces the salt when the configuration is printed.
+const redactedSalt = "<redacted>"
+
+// MarshalYAML redacts the salt, as knowing it makes dictionary attacks against the hashed values trivial.
+func (h hashTransform) MarshalYAML() (any, error) {
+	salt := h.Salt
+	if salt != "" {
+		salt = redactedSalt
+	}
+	return struct {
+		Salt string `yaml:"salt"`
+	}{salt}, nil
+}
+
+// maskTransform replaces the matches of the regular expression, by *** if the replacement is empty.
+type maskTransform struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement,omitempty"`
+}
+
+// cidrTransform replaces an IP address, optionally with port, by the network of the given prefix length.
+type cidrTransform struct {
+	IPv4 int `yaml:"ipv4"`
+	IPv6 int `yaml:"ipv6"`
+}
+
+// valueTransforms maps attribute keys to the transforms of their values, applied in the order of the rules.
+type valueTransforms map[string][]func(string) string
+
+// newValueTransforms compiles the rules. It returns nil if there are no rules.
+func newValueTransforms(rules []transformRule) (valueTransforms, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	var errs []error
+	transforms := make(valueTransforms)
+	for i, rule := range rules {
+		transform, err := rule.compile()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("transforms[%d]: %w", i, err))
+			continue
+		}
+		transforms[rule.Key] = append(transforms[rule.Key], transform)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return transforms, nil
+}
+
+func (r transformRule) compile() (func(string) string, error) {
+	if r.Key == "" {
+		return nil, errors.New("key must not be empty")
+	}
+
+	var transforms []func(string) string
+	if r.Hash != nil {
+		salt := r.Hash.Salt
+		transforms = append(transforms, func(value string) string {
+			sum := sha256.Sum256([]byte(salt + value))
+			return hex.EncodeToString(sum[:])
+		})
+	}
+	if r.Truncate < 0 {
+		return nil, fmt.Errorf("truncate must not be negative, got %d", r.Truncate)
+	} else if r.Truncate > 0 {
+		transforms = append(transforms, func(value string) string {
+			return truncateRunes(value, r.Truncate)
+		})
+	}
+	if r.Mask != nil {
+		re, err := regexp.Compile(r.Mask.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("mask: %w", err)
+		}
+		replacement := r.Mask.Replacement
+		if replacement == "" {
+			replacement = "***"
+		}
+		transforms = append(transforms, func(value string) string {
+			return re.ReplaceAllString(value, replacement)
+		})
+	}
+	if r.CIDR != nil {
+		if r.CIDR.IPv4 < 0 || r.CIDR.IPv4 > 32 || r.CIDR.IPv6 < 0 || r.CIDR.IPv6 > 128 {
+			return nil, fmt.Errorf("cidr prefix lengths must be 0-32 for ipv4 and 0-128 for ipv6, got %d and %d", r.CIDR.IPv4, r.CIDR.IPv6)
+		}
+		cidr := *r.CIDR
+		transforms = append(transforms, cidr.apply)
+	}
+
+	if len(transforms) != 1 {
+		return nil, fmt.Errorf("key %s must have exactly one of hash, truncate, mask or cidr, got %d", r.Key, len(transforms))
+	}
+	return transforms[0], nil
+}
+
+func (c cidrTransform) apply(value string) string {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		addrPort, err := netip.ParseAddrPort(value)
+		if err != nil {
+			return invalidAddress
+		}
+		addr = addrPort.Addr()
+	}
+	addr = addr.Unmap()
+
+	bits := c.IPv6
+	if addr.Is4() {
+		bits = c.IPv4
+	}
+	prefix, err := addr.WithZone("").Prefix(bits)
+	if err != nil {
+		return invalidAddress
+	}
+	return prefix.String()
+}
+
+// truncateRunes returns the first n characters of value.
+func truncateRunes(value string, n int) string {
+	if utf8.RuneCountInString(value) <= n {
+		return value
+	}
+	i := 0
+	for range n {
+		_, size := utf8.DecodeRuneInString(value[i:])
+		i += size
+	}
+	return value[:i]
+}
+
+// apply transforms a value of key. It reports whether there were transforms for key.
+func (t valueTransforms) apply(key string, value string) (string, bool) {
+	transforms, ok := t[key]
+	for _, transform := range transforms {
+		value = transform(value)
+	}
+	return value, ok
+}
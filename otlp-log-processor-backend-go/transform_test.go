@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"testing"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	otelcommon "go.opentelemetry.io/proto/otlp/common/v1"
+	otellogs "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+func TestValueTransforms_Apply(t *testing.T) {
+	sum := sha256.Sum256([]byte("pepper" + "jane@example.com"))
+
+	tests := map[string]struct {
+		rules    []transformRule
+		key      string
+		value    string
+		expected string
+	}{
+		"Hash": {
+			rules:    []transformRule{{Key: "user.email", Hash: &hashTransform{Salt: "pepper"}}},
+			key:      "user.email",
+			value:    "jane@example.com",
+			expected: hex.EncodeToString(sum[:]),
+		},
+		"Truncate": {
+			rules:    []transformRule{{Key: "user.name", Truncate: 3}},
+			key:      "user.name",
+			value:    "Jürgen",
+			expected: "Jür",
+		},
+		"TruncateShort": {
+			rules:    []transformRule{{Key: "user.name", Truncate: 10}},
+			key:      "user.name",
+			value:    "Jane",
+			expected: "Jane",
+		},
+		"Mask": {
+			rules:    []transformRule{{Key: "user.email", Mask: &maskTransform{Regex: `^[^@]+`}}},
+			key:      "user.email",
+			value:    "jane@example.com",
+			expected: "***@example.com",
+		},
+		"MaskReplacement": {
+			rules:    []transformRule{{Key: "card", Mask: &maskTransform{Regex: `\d{12}(\d{4})`, Replacement: "xxxx$1"}}},
+			key:      "card",
+			value:    "4111111111111111",
+			expected: "xxxx1111",
+		},
+		"CIDRv4": {
+			rules:    []transformRule{{Key: "client.address", CIDR: &cidrTransform{IPv4: 24, IPv6: 48}}},
+			key:      "client.address",
+			value:    "192.168.17.42",
+			expected: "192.168.17.0/24",
+		},
+		"CIDRv4WithPort": {
+			rules:    []transformRule{{Key: "client.address", CIDR: &cidrTransform{IPv4: 16, IPv6: 48}}},
+			key:      "client.address",
+			value:    "192.168.17.42:8080",
+			expected: "192.168.0.0/16",
+		},
+		"CIDRv6": {
+			rules:    []transformRule{{Key: "client.address", CIDR: &cidrTransform{IPv4: 24, IPv6: 48}}},
+			key:      "client.address",
+			value:    "2001:db8:1234:5678::1",
+			expected: "2001:db8:1234::/48",
+		},
+		"CIDRv4MappedV6": {
+			rules:    []transformRule{{Key: "client.address", CIDR: &cidrTransform{IPv4: 24, IPv6: 48}}},
+			key:      "client.address",
+			value:    "::ffff:10.1.2.3",
+			expected: "10.1.2.0/24",
+		},
+		"CIDRInvalid": {
+			rules:    []transformRule{{Key: "client.address", CIDR: &cidrTransform{IPv4: 24, IPv6: 48}}},
+			key:      "client.address",
+			value:    "jane.example.com",
+			expected: invalidAddress,
+		},
+		"InOrder": {
+			rules: []transformRule{
+				{Key: "user.email", Mask: &maskTransform{Regex: `@.*`, Replacement: "@"}},
+				{Key: "user.email", Truncate: 2},
+			},
+			key:      "user.email",
+			value:    "jane@example.com",
+			expected: "ja",
+		},
+		"OtherKey": {
+			rules:    []transformRule{{Key: "user.email", Truncate: 2}},
+			key:      "service.name",
+			value:    "checkout",
+			expected: "checkout",
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			transforms, err := newValueTransforms(test.rules)
+			if err != nil {
+				t.Fatalf("newValueTransforms failed: %v", err)
+			}
+			if got, _ := transforms.apply(test.key, test.value); got != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewValueTransforms_Invalid(t *testing.T) {
+	tests := map[string]struct {
+		rule transformRule
+	}{
+		"NoKey": {
+			rule: transformRule{Truncate: 3},
+		},
+		"NoTransform": {
+			rule: transformRule{Key: "user.email"},
+		},
+		"TwoTransforms": {
+			rule: transformRule{Key: "user.email", Truncate: 3, Hash: &hashTransform{}},
+		},
+		"NegativeTruncate": {
+			rule: transformRule{Key: "user.email", Truncate: -1},
+		},
+		"InvalidMask": {
+			rule: transformRule{Key: "user.email", Mask: &maskTransform{Regex: "("}},
+		},
+		"InvalidPrefixLength": {
+			rule: transformRule{Key: "client.address", CIDR: &cidrTransform{IPv4: 33, IPv6: 48}},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			if _, err := newValueTransforms([]transformRule{test.rule}); err == nil {
+				t.Errorf("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestValueExtractor_Extract_Transforms(t *testing.T) {
+	transforms, err := newValueTransforms([]transformRule{{Key: "client.address", CIDR: &cidrTransform{IPv4: 24, IPv6: 48}}})
+	if err != nil {
+		t.Fatalf("newValueTransforms failed: %v", err)
+	}
+	renderer, _ := newValueRenderer(renderingCanonical, true)
+	request := &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*otellogs.ResourceLogs{{
+			ScopeLogs: []*otellogs.ScopeLogs{{
+				LogRecords: []*otellogs.LogRecord{
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("client.address", "10.0.0.1")}},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("client.address", "10.0.0.2")}},
+					{Attributes: []*otelcommon.KeyValue{stringKeyValue("net.peer.name", "10.0.1.1")}},
+				},
+			}},
+		}},
+	}
+
+	tests := map[string]struct {
+		extractor valueExtractor
+		expected  []string
+	}{
+		"AttributeKey": {
+			extractor: valueExtractor{attributeKey: "client.address", transforms: transforms},
+			expected:  []string{"10.0.0.0/24", "10.0.0.0/24"},
+		},
+		"TypedAsString": {
+			extractor: valueExtractor{attributeKey: "client.address", transforms: transforms, renderer: renderer},
+			expected:  []string{"string:10.0.0.0/24", "string:10.0.0.0/24"},
+		},
+		"MatchedKey": {
+			extractor: valueExtractor{keyPattern: mustKeyPattern(t, "client.*|net.*"), countKey: true, transforms: transforms},
+			expected:  []string{"client.address=10.0.0.0/24", "client.address=10.0.0.0/24", "net.peer.name=10.0.1.1"},
+		},
+	}
+
+	for testName, test := range tests {
+		t.Run(testName, func(t *testing.T) {
+			values, _ := test.extractor.extract(context.Background(), request)
+			slices.Sort(values)
+			if !slices.Equal(values, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, values)
+			}
+		})
+	}
+}
+
+func mustKeyPattern(t *testing.T, key string) *regexp.Regexp {
+	t.Helper()
+	pattern, err := newKeyPattern(key, keyMatchRegex)
+	if err != nil {
+		t.Fatalf("newKeyPattern failed: %v", err)
+	}
+	return pattern
+}
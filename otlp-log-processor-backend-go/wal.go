@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	walSegmentPrefix    = "wal-"
+	walSegmentSuffix    = ".log"
+	walCheckpointFile   = "checkpoint"
+	walMaxSegmentSize   = 16 << 20
+	walMaxRecordSize    = 64 << 20
+	walRecordHeaderSize = 8
+	walSyncInterval     = time.Second
+	walFsyncAlways      = "always"
+	walFsyncInterval    = "interval"
+	walFsyncNever       = "never"
+)
+
+var walCRCTable = crc32.MakeTable(crc32.Castagnoli)
+
+// writeAheadLog persists the extracted log values before Export acknowledges them.
+//
+// Every value gets a sequence number. The processor checkpoints the sequence number up to which it has aggregated
+// the values, so replaying the log on startup restores only the values that were accepted but not yet processed.
+// The log is split into segments named after the sequence number of their first value.
+// A segment is removed once all of its values are checkpointed.
+//
+// Each record of a segment holds a batch of values:
+//
+//	uint32 payload length | uint32 CRC-32C of payload | uint64 first sequence | uvarint count | (uvarint length | value)*
+type writeAheadLog struct {
+	dir   string
+	fsync string
+
+	mu            sync.Mutex
+	segment       *os.File
+	segmentSize   int64
+	segments      []uint64
+	nextSeq       uint64
+	checkpointSeq uint64
+	dirty         bool
+	closed        chan struct{}
+	stopped       chan struct{}
+
+	// failed is set if a failed append could not be rolled back, the log refuses further appends.
+	failed error
+}
+
+// openWAL opens the write-ahead log in dir, creating dir if necessary.
+// A torn record at the end of the last segment, left behind by a crash during a write, is truncated.
+func openWAL(dir string, fsync string) (*writeAheadLog, error) {
+	switch fsync {
+	case walFsyncAlways, walFsyncInterval, walFsyncNever:
+	default:
+		return nil, fmt.Errorf("invalid WAL fsync policy %q, expected %s, %s or %s", fsync, walFsyncAlways, walFsyncInterval, walFsyncNever)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating WAL directory: %w", err)
+	}
+
+	w := &writeAheadLog{
+		dir:     dir,
+		fsync:   fsync,
+		closed:  make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	var err error
+	if w.checkpointSeq, err = w.readCheckpoint(); err != nil {
+		return nil, err
+	}
+	if w.segments, err = w.listSegments(); err != nil {
+		return nil, err
+	}
+
+	w.nextSeq = w.checkpointSeq
+	if len(w.segments) > 0 {
+		last := w.segments[len(w.segments)-1]
+		end, validSize, err := w.scanSegment(last, nil)
+		if err != nil {
+			return nil, err
+		}
+		w.nextSeq = max(end, w.checkpointSeq)
+
+		w.segment, err = os.OpenFile(w.segmentPath(last), os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("opening WAL segment: %w", err)
+		}
+		if err := w.segment.Truncate(validSize); err != nil {
+			return nil, fmt.Errorf("truncating torn WAL record: %w", err)
+		}
+		if _, err := w.segment.Seek(validSize, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seeking WAL segment: %w", err)
+		}
+		w.segmentSize = validSize
+	}
+
+	if w.fsync == walFsyncInterval {
+		go w.syncPeriodically()
+	} else {
+		close(w.stopped)
+	}
+
+	return w, nil
+}
+
+// append writes the values as a single record and returns once the policy of fsync is fulfilled.
+func (w *writeAheadLog) append(values []string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	// The first sequence is filled in once the lock is held.
+	record := make([]byte, walRecordHeaderSize+8, walRecordHeaderSize+8+binary.MaxVarintLen64)
+	record = binary.AppendUvarint(record, uint64(len(values)))
+	for _, value := range values {
+		record = binary.AppendUvarint(record, uint64(len(value)))
+		record = append(record, value...)
+	}
+	payload := record[walRecordHeaderSize:]
+	binary.BigEndian.PutUint32(record, uint32(len(payload)))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.failed != nil {
+		return fmt.Errorf("WAL failed: %w", w.failed)
+	}
+
+	binary.BigEndian.PutUint64(payload, w.nextSeq)
+	binary.BigEndian.PutUint32(record[4:], crc32.Checksum(payload, walCRCTable))
+
+	if w.segment == nil || w.segmentSize >= walMaxSegmentSize {
+		if err := w.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.segment.Write(record); err != nil {
+		return w.rollbackLocked(fmt.Errorf("writing WAL record: %w", err))
+	}
+	if w.fsync == walFsyncAlways {
+		if err := w.segment.Sync(); err != nil {
+			return w.rollbackLocked(fmt.Errorf("syncing WAL segment: %w", err))
+		}
+	}
+	w.segmentSize += int64(len(record))
+	w.nextSeq += uint64(len(values))
+	if w.fsync == walFsyncInterval {
+		w.dirty = true
+	}
+	return nil
+}
+
+// rollbackLocked removes the record of a failed append from the segment, so a torn record does not hide the records
+// appended after it and the sequence numbers stay in line with the values Export hands to the processor.
+// If the record cannot be removed, the log is marked failed.
+func (w *writeAheadLog) rollbackLocked(err error) error {
+	if truncateErr := w.segment.Truncate(w.segmentSize); truncateErr != nil {
+		w.failed = fmt.Errorf("rolling back WAL record: %w", truncateErr)
+		return errors.Join(err, w.failed)
+	}
+	if _, seekErr := w.segment.Seek(w.segmentSize, io.SeekStart); seekErr != nil {
+		w.failed = fmt.Errorf("rolling back WAL record: %w", seekErr)
+		return errors.Join(err, w.failed)
+	}
+	return err
+}
+
+// checkpoint records that all values before seq are processed and removes the segments which are no longer needed.
+func (w *writeAheadLog) checkpoint(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if seq <= w.checkpointSeq {
+		return nil
+	}
+
+	if err := writeFileAtomic(filepath.Join(w.dir, walCheckpointFile), func(file io.Writer) error {
+		_, err := fmt.Fprintf(file, "%d\n", seq)
+		return err
+	}); err != nil {
+		return fmt.Errorf("writing WAL checkpoint: %w", err)
+	}
+	w.checkpointSeq = seq
+
+	// Start a new segment if the active one is fully processed, so it can be removed right away.
+	if seq >= w.nextSeq && w.segmentSize > 0 {
+		if err := w.rotateLocked(); err != nil {
+			return err
+		}
+	}
+
+	// A segment is fully processed once the following segment starts at or before the checkpoint.
+	for len(w.segments) > 1 && w.segments[1] <= seq {
+		if err := os.Remove(w.segmentPath(w.segments[0])); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("removing WAL segment: %w", err)
+		}
+		w.segments = w.segments[1:]
+	}
+	return nil
+}
+
+// readFrom returns the values starting at sequence from, i.e. the tail which is not yet processed.
+func (w *writeAheadLog) readFrom(from uint64) ([]string, error) {
+	w.mu.Lock()
+	segments := slices.Clone(w.segments)
+	w.mu.Unlock()
+
+	var values []string
+	for _, first := range segments {
+		if _, _, err := w.scanSegment(first, func(seq uint64, value string) {
+			if seq >= from {
+				values = append(values, value)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// Close syncs and closes the active segment.
+func (w *writeAheadLog) Close() error {
+	close(w.closed)
+	<-w.stopped
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.segment == nil {
+		return nil
+	}
+	err := errors.Join(w.segment.Sync(), w.segment.Close())
+	w.segment = nil
+	return err
+}
+
+func (w *writeAheadLog) syncPeriodically() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(walSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.closed:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.dirty && w.segment != nil {
+				if err := w.segment.Sync(); err != nil {
+					slog.Error("Failed to sync WAL segment", slog.Any("error", err))
+				}
+				w.dirty = false
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// rotateLocked closes the active segment and starts a new one at the next sequence. The caller must hold w.mu.
+func (w *writeAheadLog) rotateLocked() error {
+	if w.segment != nil {
+		if err := errors.Join(w.segment.Sync(), w.segment.Close()); err != nil {
+			return fmt.Errorf("closing WAL segment: %w", err)
+		}
+		w.segment = nil
+	}
+
+	file, err := os.OpenFile(w.segmentPath(w.nextSeq), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating WAL segment: %w", err)
+	}
+	if err := syncDir(w.dir); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("syncing WAL directory: %w", err)
+	}
+	w.segment = file
+	w.segmentSize = 0
+	w.segments = append(w.segments, w.nextSeq)
+	return nil
+}
+
+// scanSegment calls fn for every value of the segment starting at first.
+// It returns the sequence following the last valid value and the size of the valid part of the segment.
+// Reading stops at the first torn or corrupt record.
+func (w *writeAheadLog) scanSegment(first uint64, fn func(seq uint64, value string)) (end uint64, validSize int64, err error) {
+	file, err := os.Open(w.segmentPath(first))
+	if err != nil {
+		return 0, 0, fmt.Errorf("opening WAL segment: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	end = first
+	header := make([]byte, walRecordHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			return end, validSize, nil
+		}
+		length := binary.BigEndian.Uint32(header)
+		if length > walMaxRecordSize {
+			return end, validSize, nil
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			return end, validSize, nil
+		}
+		if crc32.Checksum(payload, walCRCTable) != binary.BigEndian.Uint32(header[4:]) {
+			return end, validSize, nil
+		}
+
+		seq, values, ok := decodeWALPayload(payload)
+		if !ok {
+			return end, validSize, nil
+		}
+		for i, value := range values {
+			if fn != nil {
+				fn(seq+uint64(i), value)
+			}
+		}
+		end = seq + uint64(len(values))
+		validSize += int64(walRecordHeaderSize) + int64(length)
+	}
+}
+
+func decodeWALPayload(payload []byte) (seq uint64, values []string, ok bool) {
+	if len(payload) < 8 {
+		return 0, nil, false
+	}
+	seq = binary.BigEndian.Uint64(payload)
+	payload = payload[8:]
+
+	count, n := binary.Uvarint(payload)
+	if n <= 0 || count > uint64(len(payload)) {
+		return 0, nil, false
+	}
+	payload = payload[n:]
+
+	values = make([]string, 0, count)
+	for range count {
+		length, n := binary.Uvarint(payload)
+		if n <= 0 || length > uint64(len(payload)-n) {
+			return 0, nil, false
+		}
+		values = append(values, string(payload[n:n+int(length)]))
+		payload = payload[n+int(length):]
+	}
+	return seq, values, true
+}
+
+func (w *writeAheadLog) readCheckpoint() (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(w.dir, walCheckpointFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("reading WAL checkpoint: %w", err)
+	}
+	seq, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parsing WAL checkpoint: %w", err)
+	}
+	return seq, nil
+}
+
+func (w *writeAheadLog) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, fmt.Errorf("listing WAL directory: %w", err)
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, walSegmentPrefix) || !strings.HasSuffix(name, walSegmentSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, walSegmentPrefix), walSegmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, first)
+	}
+	slices.Sort(segments)
+	return segments, nil
+}
+
+func (w *writeAheadLog) segmentPath(first uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%s%020d%s", walSegmentPrefix, first, walSegmentSuffix))
+}
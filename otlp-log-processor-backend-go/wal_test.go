@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func openTestWAL(t *testing.T, dir string) *writeAheadLog {
+	t.Helper()
+	wal, err := openWAL(dir, walFsyncAlways)
+	if err != nil {
+		t.Fatalf("openWAL failed: %v", err)
+	}
+	return wal
+}
+
+func TestWriteAheadLog_AppendAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	wal := openTestWAL(t, dir)
+
+	if err := wal.append([]string{"checkout", "cart"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := wal.append([]string{"payment"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := wal.checkpoint(1); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened := openTestWAL(t, dir)
+	defer reopened.Close()
+
+	if reopened.checkpointSeq != 1 || reopened.nextSeq != 3 {
+		t.Errorf("Expected checkpoint 1 and next sequence 3, got %d and %d", reopened.checkpointSeq, reopened.nextSeq)
+	}
+
+	unprocessed, err := reopened.readFrom(reopened.checkpointSeq)
+	if err != nil {
+		t.Fatalf("readFrom failed: %v", err)
+	}
+	expected := []string{"cart", "payment"}
+	if !reflect.DeepEqual(unprocessed, expected) {
+		t.Errorf("Expected unprocessed values %v, got %v", expected, unprocessed)
+	}
+
+	if err := reopened.append([]string{"search"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	unprocessed, _ = reopened.readFrom(3)
+	if !reflect.DeepEqual(unprocessed, []string{"search"}) {
+		t.Errorf("Expected appended value to continue the sequence, got %v", unprocessed)
+	}
+}
+
+func TestWriteAheadLog_TornRecord(t *testing.T) {
+	dir := t.TempDir()
+	wal := openTestWAL(t, dir)
+	if err := wal.append([]string{"checkout"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := wal.append([]string{"cart"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	segment := wal.segmentPath(wal.segments[0])
+	size := wal.segmentSize
+	if err := wal.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Simulate a crash during the second write.
+	if err := os.Truncate(segment, size-2); err != nil {
+		t.Fatalf("Truncate failed: %v", err)
+	}
+
+	reopened := openTestWAL(t, dir)
+	defer reopened.Close()
+
+	if reopened.nextSeq != 1 {
+		t.Errorf("Expected next sequence 1 after dropping the torn record, got %d", reopened.nextSeq)
+	}
+	if err := reopened.append([]string{"payment"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	unprocessed, err := reopened.readFrom(0)
+	if err != nil {
+		t.Fatalf("readFrom failed: %v", err)
+	}
+	if !reflect.DeepEqual(unprocessed, []string{"checkout", "payment"}) {
+		t.Errorf("Expected the torn record to be replaced, got %v", unprocessed)
+	}
+}
+
+func TestWriteAheadLog_RollbackPartialWrite(t *testing.T) {
+	dir := t.TempDir()
+	wal := openTestWAL(t, dir)
+	defer wal.Close()
+	if err := wal.append([]string{"checkout"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+
+	// Simulate a write which failed after a part of the record.
+	if _, err := wal.segment.Write([]byte{0, 0, 0, 42, 1}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	writeErr := errors.New("disk full")
+	if err := wal.rollbackLocked(writeErr); !errors.Is(err, writeErr) || wal.failed != nil {
+		t.Fatalf("Expected the rollback to return the write error, got %v and failed %v", err, wal.failed)
+	}
+
+	if err := wal.append([]string{"cart"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if wal.nextSeq != 2 {
+		t.Errorf("Expected next sequence 2, got %d", wal.nextSeq)
+	}
+	unprocessed, err := wal.readFrom(0)
+	if err != nil {
+		t.Fatalf("readFrom failed: %v", err)
+	}
+	if !reflect.DeepEqual(unprocessed, []string{"checkout", "cart"}) {
+		t.Errorf("Expected the partial record to be removed, got %v", unprocessed)
+	}
+}
+
+func TestWriteAheadLog_FailedAppend(t *testing.T) {
+	wal := openTestWAL(t, t.TempDir())
+	if err := wal.append([]string{"checkout"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+
+	// Neither the write nor its rollback can succeed on a closed segment.
+	if err := wal.segment.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := wal.append([]string{"cart"}); err == nil {
+		t.Fatal("Expected append to fail on a closed segment")
+	}
+	if wal.nextSeq != 1 {
+		t.Errorf("Expected the next sequence to stay at 1, got %d", wal.nextSeq)
+	}
+	if err := wal.append([]string{"payment"}); err == nil || wal.failed == nil {
+		t.Errorf("Expected the failed log to refuse further appends, got %v", err)
+	}
+}
+
+func TestWriteAheadLog_CheckpointRemovesSegments(t *testing.T) {
+	dir := t.TempDir()
+	wal := openTestWAL(t, dir)
+	defer wal.Close()
+
+	if err := wal.append([]string{"checkout", "cart"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := wal.checkpoint(2); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	if err := wal.append([]string{"payment"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := wal.checkpoint(3); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+
+	segments, err := filepath.Glob(filepath.Join(dir, walSegmentPrefix+"*"))
+	if err != nil {
+		t.Fatalf("Glob failed: %v", err)
+	}
+	if len(segments) != 1 || filepath.Base(segments[0]) != filepath.Base(wal.segmentPath(3)) {
+		t.Errorf("Expected only the empty active segment to remain, got %v", segments)
+	}
+}
+
+func TestOpenWAL_InvalidFsync(t *testing.T) {
+	if _, err := openWAL(t.TempDir(), "sometimes"); err == nil {
+		t.Error("Expected an error for an invalid fsync policy")
+	}
+}
+
+func TestLogsServiceServer_Export_WAL(t *testing.T) {
+	wal := openTestWAL(t, t.TempDir())
+	defer wal.Close()
+
+	logExportChannel := make(chan string, 10)
+	server := &dash0LogsServiceServer{
+		addr:         "localhost:4317",
+		attributeKey: "service.name",
+		logExport:    logExportChannel,
+		wal:          wal,
+	}
+
+	if _, err := server.Export(context.Background(), createLogRecordAttributesRequest()); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+
+	persisted, err := wal.readFrom(0)
+	if err != nil {
+		t.Fatalf("readFrom failed: %v", err)
+	}
+	if !reflect.DeepEqual(persisted, []string{"test-log-service"}) {
+		t.Errorf("Expected the value to be persisted before Export returns, got %v", persisted)
+	}
+	if exported := <-logExportChannel; exported != "test-log-service" {
+		t.Errorf("Expected 'test-log-service', got '%s'", exported)
+	}
+}
+
+func TestDash0LogsProcessor_WALReplayAndCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	wal := openTestWAL(t, dir)
+	if err := wal.append([]string{"checkout", "cart", "checkout"}); err != nil {
+		t.Fatalf("append failed: %v", err)
+	}
+	if err := wal.checkpoint(1); err != nil {
+		t.Fatalf("checkpoint failed: %v", err)
+	}
+	unprocessed, err := wal.readFrom(wal.checkpointSeq)
+	if err != nil {
+		t.Fatalf("readFrom failed: %v", err)
+	}
+
+	server := newServer("localhost:4317", "service.name", time.Hour, 10,
+		withWAL(wal, wal.checkpointSeq, unprocessed, time.Hour))
+	if err := server.enqueue([]string{"payment"}); err != nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+	server.processor.Stop()
+	if err := wal.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if server.processor.logStats["checkout"] != 1 || server.processor.logStats["cart"] != 1 || server.processor.logStats["payment"] != 1 {
+		t.Errorf("Expected the unprocessed tail and new values to be counted, got %v", server.processor.logStats)
+	}
+
+	reopened := openTestWAL(t, dir)
+	defer reopened.Close()
+	if reopened.checkpointSeq != 4 {
+		t.Errorf("Expected checkpoint 4 after stopping, got %d", reopened.checkpointSeq)
+	}
+}